@@ -1,6 +1,6 @@
 #!/usr/bin/env bash
 # Set versions of software required
-linter_version=1.30.0
+linter_version=1.50.1
 mockgen_version=v1.4.4
 
 function usage()
@@ -33,7 +33,7 @@ if [ $? -eq 0 ]; then
 fi
 
 echo "Running setup script to setup software"
-GO111MODULE=on go get mvdan.cc/gofumpt/gofumports
+go install mvdan.cc/gofumpt/gofumports@v0.1.1
 
 golangci-lint --version 2>&1 | grep $linter_version >/dev/null
 ret_code="${?}"
@@ -54,7 +54,7 @@ mockgen -version 2>&1 | grep ${mockgen_version} >/dev/null
 ret_code="${?}"
 if [[ "${ret_code}" != "0" ]] ; then
     echo "installing mockgen version: ${mockgen_version}"
-    GO111MODULE=on go get github.com/golang/mock/mockgen@${mockgen_version}
+    go install github.com/golang/mock/mockgen@${mockgen_version}
     mockgen -version 2>&1 | grep ${mockgen_version} >/dev/null
     ret_code="${?}"
     if [ "${ret_code}" != "0" ] ; then
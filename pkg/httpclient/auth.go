@@ -0,0 +1,231 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultServiceAccountTokenFile is the file a Kubernetes pod's service account token is mounted at.
+const DefaultServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token" // nolint:gosec // ok
+
+var ErrorAuthentication = errors.New("failed to authenticate request")
+
+func authenticationError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrorAuthentication, msg)
+}
+
+// Authenticator adds credentials to requests, it is called for every attempt of a request.
+type Authenticator interface {
+	// Authenticate adds credentials to the request.
+	Authenticate(req *http.Request) error
+	// Invalidate is called when a request is rejected with a 401 status, it discards any cached credentials and
+	// returns true if the request should be sent again with fresh credentials.
+	Invalidate() bool
+}
+
+type staticAuth struct {
+	header string
+}
+
+// BearerToken returns an Authenticator sending a static bearer token.
+func BearerToken(token string) Authenticator {
+	return &staticAuth{header: "Bearer " + token}
+}
+
+// BasicAuth returns an Authenticator using HTTP basic authentication.
+func BasicAuth(username, password string) Authenticator {
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth(username, password)
+
+	return &staticAuth{header: req.Header.Get("Authorization")}
+}
+
+// Authenticate implements Authenticator.
+func (a *staticAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", a.header)
+
+	return nil
+}
+
+// Invalidate implements Authenticator, static credentials cannot be refreshed.
+func (a *staticAuth) Invalidate() bool {
+	return false
+}
+
+// ClientCredentials is an Authenticator using the OAuth2 client credentials grant. Tokens are cached until shortly
+// before they expire, or until a request using them is rejected with a 401 status.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Params       url.Values    // Additional parameters sent to the token endpoint.
+	ExpiryDelta  time.Duration // How long before expiry tokens are refreshed, defaults to ten seconds.
+	HTTPClient   *http.Client  // Client used to request tokens, defaults to one using the shared transport.
+
+	mutex  sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// tokenResponse is the token endpoint response, see RFC 6749 section 5.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Authenticate implements Authenticator, requesting a token if there is no cached token or it is about to expire.
+func (c *ClientCredentials) Authenticate(req *http.Request) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delta := c.ExpiryDelta
+	if delta == 0 {
+		delta = ten * time.Second
+	}
+
+	if len(c.token) == 0 || (!c.expiry.IsZero() && time.Now().Add(delta).After(c.expiry)) {
+		if err := c.fetchToken(req.Context()); err != nil {
+			return err
+		}
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	return nil
+}
+
+// Invalidate implements Authenticator, discarding the cached token.
+func (c *ClientCredentials) Invalidate() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.token = ""
+
+	return true
+}
+
+// fetchToken requests a token from the token endpoint, sending the client credentials using basic authentication.
+func (c *ClientCredentials) fetchToken(ctx context.Context) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+
+	for name, values := range c.Params {
+		form[name] = values
+	}
+
+	req, err := http.NewRequestWithContext(ctx, Post, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return authenticationError(err.Error())
+	}
+
+	req.Header.Set(ContentType, AppForm)
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Transport: defaultTransport(), Timeout: DefaultTimeout}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return authenticationError(err.Error())
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return authenticationError(err.Error())
+	}
+
+	if !Is2xx(resp.StatusCode) {
+		return authenticationError(fmt.Sprintf("token request failed: %s %s", resp.Status, data))
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(data, &token); err != nil {
+		return authenticationError(fmt.Sprintf("invalid token response: %s", err))
+	}
+
+	if len(token.AccessToken) == 0 {
+		return authenticationError("token response has no access token")
+	}
+
+	c.token = token.AccessToken
+	c.expiry = time.Time{}
+
+	if token.ExpiresIn > 0 {
+		c.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return nil
+}
+
+type serviceAccountAuth struct {
+	mutex   sync.Mutex
+	path    string
+	token   string
+	modTime time.Time
+}
+
+// ServiceAccountToken returns an Authenticator sending the bearer token read from a Kubernetes service account
+// token file, DefaultServiceAccountTokenFile if path is empty. The file is read again when it changes so rotated
+// tokens are used.
+func ServiceAccountToken(path string) Authenticator {
+	if len(path) == 0 {
+		path = DefaultServiceAccountTokenFile
+	}
+
+	return &serviceAccountAuth{path: path}
+}
+
+// Authenticate implements Authenticator.
+func (a *serviceAccountAuth) Authenticate(req *http.Request) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return authenticationError(err.Error())
+	}
+
+	if len(a.token) == 0 || !info.ModTime().Equal(a.modTime) {
+		data, err := ioutil.ReadFile(a.path)
+		if err != nil {
+			return authenticationError(err.Error())
+		}
+
+		a.token, a.modTime = strings.TrimSpace(string(data)), info.ModTime()
+	}
+
+	if len(a.token) == 0 {
+		return authenticationError(fmt.Sprintf("token file %s is empty", a.path))
+	}
+
+	req.Header.Set("Authorization", "Bearer "+a.token)
+
+	return nil
+}
+
+// Invalidate implements Authenticator, the token file is read again for the next request.
+func (a *serviceAccountAuth) Invalidate() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.token = ""
+
+	return true
+}
@@ -0,0 +1,167 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+)
+
+// authServer responds with the request's Authorization header, rejecting requests without the accepted header.
+func authServer(t *testing.T, accepted *atomic.Value) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if accepted != nil && header != accepted.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+
+		fmt.Fprint(w, header)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// tokenServer is an OAuth2 token endpoint issuing numbered tokens.
+func tokenServer(t *testing.T, expiresIn int, calls *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "s3cr3t" || r.FormValue("grant_type") != "client_credentials" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+
+			return
+		}
+
+		fmt.Fprintf(w, `{"access_token":"token-%d-%s","token_type":"Bearer","expires_in":%d}`,
+			atomic.AddInt32(calls, 1), r.FormValue("scope"), expiresIn)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func authGet(t *testing.T, url string, auth httpclient.Authenticator) (string, error) {
+	client, err := httpclient.NewClient(httpclient.WithAuth(auth), httpclient.WithRetryPolicy(nil))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	r, err := client.Get(context.Background(), url).Do()
+	if err != nil {
+		return "", err
+	}
+
+	return r.RespBody(), nil
+}
+
+func TestStaticAuth(t *testing.T) {
+	server := authServer(t, nil)
+
+	tests := []struct {
+		testNum  int
+		auth     httpclient.Authenticator
+		expected string
+	}{
+		{1, nil, ""},
+		{2, httpclient.BearerToken("abc"), "Bearer abc"},
+		{3, httpclient.BasicAuth("user", "pass"), "Basic dXNlcjpwYXNz"},
+	}
+
+	for _, test := range tests {
+		if result, err := authGet(t, server.URL, test.auth); err != nil || result != test.expected {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %s, %v", test.testNum, test.expected, result, err)
+		}
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	var calls int32
+
+	accepted := &atomic.Value{}
+	accepted.Store("Bearer token-1-read write")
+	server := authServer(t, accepted)
+	tokens := tokenServer(t, 3600, &calls)
+
+	auth := &httpclient.ClientCredentials{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cr3t", Scopes: []string{"read", "write"}}
+
+	tests := []struct {
+		testNum  int
+		accepted string
+		expected string
+		calls    int32
+	}{
+		{1, "Bearer token-1-read write", "Bearer token-1-read write", 1},
+		{2, "Bearer token-1-read write", "Bearer token-1-read write", 1},
+		{3, "Bearer token-2-read write", "Bearer token-2-read write", 2},
+		{4, "Bearer other", "", 3},
+	}
+
+	for _, test := range tests {
+		accepted.Store(test.accepted)
+
+		result, err := authGet(t, server.URL, auth)
+		if result != test.expected || (err != nil) != (len(test.expected) == 0) || atomic.LoadInt32(&calls) != test.calls {
+			t.Errorf("\nTest: %d\nExpected: %s, %d token requests\nGot.....: %s, %d token requests, %v",
+				test.testNum, test.expected, test.calls, result, atomic.LoadInt32(&calls), err)
+		}
+	}
+}
+
+func TestClientCredentialsExpiry(t *testing.T) {
+	var calls int32
+
+	server := authServer(t, nil)
+	tokens := tokenServer(t, 5, &calls)
+	auth := &httpclient.ClientCredentials{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cr3t"}
+
+	for i := 1; i <= 2; i++ {
+		if result, err := authGet(t, server.URL, auth); err != nil || result != fmt.Sprintf("Bearer token-%d-", i) {
+			t.Errorf("expected token to be refreshed before expiry, got %s, %v", result, err)
+		}
+	}
+
+	auth = &httpclient.ClientCredentials{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "wrong"}
+
+	if _, err := authGet(t, server.URL, auth); !errors.Is(err, httpclient.ErrorAuthentication) {
+		t.Errorf("expected authentication error, got %v", err)
+	}
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	server := authServer(t, nil)
+	auth := httpclient.ServiceAccountToken(tokenFile)
+
+	if _, err := authGet(t, server.URL, auth); !errors.Is(err, httpclient.ErrorAuthentication) {
+		t.Errorf("expected authentication error for missing token file, got %v", err)
+	}
+
+	tests := []struct {
+		testNum  int
+		token    string
+		modTime  time.Time
+		expected string
+	}{
+		{1, "first\n", time.Now().Add(-time.Hour), "Bearer first"},
+		{2, "second", time.Now().Add(-time.Minute), "Bearer second"},
+		{3, "second", time.Now().Add(-time.Minute), "Bearer second"},
+	}
+
+	for _, test := range tests {
+		writeFile(t, tokenFile, []byte(test.token), test.modTime)
+
+		if result, err := authGet(t, server.URL, auth); err != nil || result != test.expected {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %s, %v", test.testNum, test.expected, result, err)
+		}
+	}
+}
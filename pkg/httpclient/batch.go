@@ -0,0 +1,143 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultBatchConcurrency is the number of requests a batch sends at once if BatchOptions.Concurrency is not set.
+const DefaultBatchConcurrency = 10
+
+var ErrorBatch = errors.New("batch requests failed")
+
+func batchError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrorBatch, msg)
+}
+
+type (
+	// BatchOptions holds the settings of a batch.
+	BatchOptions struct {
+		Concurrency int // Maximum number of requests sent at once, defaults to DefaultBatchConcurrency.
+		// FailFast stops the batch when a request fails, cancelling requests in progress and not sending the
+		// remaining requests. By default all requests are sent regardless of failures.
+		FailFast bool
+	}
+
+	// BatchResult holds the outcome of a request sent as part of a batch.
+	BatchResult struct {
+		Request ReqResp
+		Err     error // Error returned by the request, the context's error if it was not sent.
+	}
+)
+
+// Batch sends the requests concurrently, returning their results in the same order as the requests. Each request is
+// sent using HTTPreq with a context that is cancelled when its own context or ctx is done, or in fail fast mode when
+// another request fails. Requests not sent because ctx is done or the batch failed have the context's error as
+// their result. An error wrapping ErrorBatch is returned if any request failed. A request must not appear in the
+// batch more than once. A nil ctx is treated as context.Background().
+func Batch(ctx context.Context, requests []ReqResp, opts *BatchOptions) ([]BatchResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	options := BatchOptions{}
+	if opts != nil {
+		options = *opts
+	}
+
+	if options.Concurrency <= 0 {
+		options.Concurrency = DefaultBatchConcurrency
+	}
+
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]BatchResult, len(requests))
+	indexes := make(chan int)
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		failures int
+		first    error
+	)
+
+	for i := 0; i < options.Concurrency && i < len(requests); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for index := range indexes {
+				err := sendInBatch(batchCtx, requests[index])
+				results[index] = BatchResult{Request: requests[index], Err: err}
+
+				if err == nil {
+					continue
+				}
+
+				mutex.Lock()
+				failures++
+
+				if first == nil && batchCtx.Err() == nil {
+					first = err
+				}
+				mutex.Unlock()
+
+				if options.FailFast {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	for i := range requests {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	if failures == 0 {
+		return results, nil
+	}
+
+	if first == nil {
+		first = ctx.Err()
+	}
+
+	return results, batchError(fmt.Sprintf("%d of %d requests failed, %s", failures, len(requests), first))
+}
+
+// sendInBatch sends the request with a context that is also cancelled when the batch context is done, restoring the
+// request's own context afterwards.
+func sendInBatch(batchCtx context.Context, req ReqResp) error {
+	if err := batchCtx.Err(); err != nil {
+		return err
+	}
+
+	r, err := asReqResp(req)
+	if err != nil {
+		return err
+	}
+
+	reqCtx := r.ctx
+	defer func() { r.ctx = reqCtx }()
+
+	ctx, cancel := context.WithCancel(reqCtx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-batchCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	r.ctx = ctx
+
+	return r.HTTPreq()
+}
@@ -0,0 +1,195 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+)
+
+// peakTransport is a RoundTripper recording the largest number of requests in progress at once.
+type peakTransport struct {
+	mutex  sync.Mutex
+	active int
+	peak   int
+}
+
+func (p *peakTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p.mutex.Lock()
+	p.active++
+	if p.active > p.peak {
+		p.peak = p.active
+	}
+	p.mutex.Unlock()
+
+	defer func() {
+		p.mutex.Lock()
+		p.active--
+		p.mutex.Unlock()
+	}()
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// reset returns the largest number of requests in progress at once since the last reset.
+func (p *peakTransport) reset() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	peak := p.peak
+	p.peak = 0
+
+	return peak
+}
+
+// expectSlow adds an expectation for requests to /slow that are held until they are cancelled.
+func expectSlow(server *mockserver.Server) {
+	server.Expect(httpclient.Get, "/slow").Respond(mockserver.Status(http.StatusOK).After(5 * time.Second))
+}
+
+func TestBatch(t *testing.T) {
+	server := mockserver.New(t)
+	for i := 1; i <= 5; i++ {
+		response := mockserver.Text(http.StatusOK, fmt.Sprint(i)).After(10 * time.Millisecond)
+		server.Expect(httpclient.Get, fmt.Sprintf("/ok/%d", i)).Respond(response)
+	}
+
+	server.Expect(httpclient.Get, "/fail").Respond(mockserver.Status(http.StatusBadRequest))
+	expectSlow(server)
+
+	transport := &peakTransport{}
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURL(server.URL), httpclient.WithRetryPolicy(nil),
+		httpclient.WithTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	tests := []struct {
+		testNum  int
+		paths    []string
+		opts     *httpclient.BatchOptions
+		expected string
+		err      error
+		peak     int // Maximum number of requests expected in progress at once.
+	}{
+		{1, []string{"ok/1", "ok/2", "ok/3", "ok/4", "ok/5"}, &httpclient.BatchOptions{Concurrency: 2}, "[1 2 3 4 5]", nil, 2},
+		{2, []string{"ok/1", "ok/2", "ok/3", "ok/4", "ok/5"}, nil, "[1 2 3 4 5]", nil, 5},
+		{3, []string{"ok/1", "fail", "ok/3"}, nil, "[1 error making request 3]", httpclient.ErrorBatch, 3},
+		{4, []string{"fail", "slow", "slow", "ok/4"}, &httpclient.BatchOptions{Concurrency: 3, FailFast: true},
+			"[error making request context canceled context canceled context canceled]", httpclient.ErrorBatch, 3},
+		{5, []string{}, nil, "[]", nil, 0},
+	}
+
+	for _, test := range tests {
+		transport.reset()
+
+		requests := []httpclient.ReqResp{}
+
+		for _, path := range test.paths {
+			r, err := client.Get(context.Background(), path).Build()
+			if err != nil {
+				t.Fatalf("failed to build request, %s", err)
+			}
+
+			requests = append(requests, r)
+		}
+
+		start := time.Now()
+		results, err := httpclient.Batch(context.Background(), requests, test.opts)
+
+		got := []string{}
+
+		for i, result := range results {
+			if result.Request != requests[i] {
+				t.Errorf("\nTest: %d\nresult %d is for the wrong request", test.testNum, i)
+			}
+
+			switch {
+			case errors.Is(result.Err, context.Canceled):
+				got = append(got, "context canceled")
+			case errors.Is(result.Err, httpclient.ErrorRequestFailed):
+				got = append(got, "error making request")
+			case result.Err != nil:
+				got = append(got, result.Err.Error())
+			default:
+				got = append(got, result.Request.RespBody())
+			}
+		}
+
+		peak := transport.reset()
+		if fmt.Sprint(got) != test.expected || !errors.Is(err, test.err) || (err == nil) != (test.err == nil) ||
+			peak > test.peak || time.Since(start) > time.Second {
+			t.Errorf("\nTest: %d\nExpected: %s, %v, up to %d at once\nGot.....: %v, %v, %d at once after %s",
+				test.testNum, test.expected, test.err, test.peak, got, err, peak, time.Since(start))
+		}
+	}
+}
+
+func TestBatchNilContext(t *testing.T) {
+	server := mockserver.New(t)
+	server.Expect(httpclient.Get, "/ok").Respond(mockserver.Text(http.StatusOK, "ok"))
+
+	r := newGet(t, server.URL+"/ok")
+
+	results, err := httpclient.Batch(nil, []httpclient.ReqResp{r}, nil) // nolint:staticcheck // testing a nil context
+	if err != nil || len(results) != 1 || results[0].Err != nil || r.RespBody() != "ok" {
+		t.Errorf("expected batch with a nil context to succeed, got %v, %+v", err, results)
+	}
+
+	server.Verify()
+}
+
+func TestBatchCancel(t *testing.T) {
+	server := mockserver.New(t)
+	expectSlow(server)
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURL(server.URL), httpclient.WithRetryPolicy(nil))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	reqCtx, reqCancel := context.WithTimeout(context.Background(), time.Minute)
+	defer reqCancel()
+
+	requests := []httpclient.ReqResp{}
+
+	for i := 0; i < 4; i++ {
+		r, err := client.Get(reqCtx, "slow").Build()
+		if err != nil {
+			t.Fatalf("failed to build request, %s", err)
+		}
+
+		requests = append(requests, r)
+	}
+
+	start := time.Now()
+
+	results, err := httpclient.Batch(ctx, requests, &httpclient.BatchOptions{Concurrency: 2})
+	if !errors.Is(err, httpclient.ErrorBatch) || time.Since(start) > time.Second {
+		t.Errorf("expected batch to stop when its context is done, got %v after %s", err, time.Since(start))
+	}
+
+	for i, result := range results {
+		if !errors.Is(result.Err, context.Canceled) && !errors.Is(result.Err, context.DeadlineExceeded) {
+			t.Errorf("expected request %d to be cancelled, got %v", i, result.Err)
+		}
+	}
+
+	// Requests are left with their own context and remain subject to it in later batches.
+	reqCancel()
+
+	results, _ = httpclient.Batch(context.Background(), requests[:1], nil)
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("expected request to use its own context, got %v", results[0].Err)
+	}
+}
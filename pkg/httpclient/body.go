@@ -0,0 +1,174 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+const (
+	ContentType      = "Content-Type"
+	AppJSON          = "application/json"
+	AppMergePatch    = "application/merge-patch+json"
+	AppJSONPatch     = "application/json-patch+json"
+	AppForm          = "application/x-www-form-urlencoded"
+	AppOctetStream   = "application/octet-stream"
+	patchOpRemove    = "remove"
+	patchOpMove      = "move"
+	patchOpCopy      = "copy"
+	multipartFileKey = `form-data; name="%s"; filename="%s"`
+)
+
+// quoteEscaper escapes quoted Content-Disposition parameters, as done by multipart.Writer.CreateFormFile.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"") // nolint:gochecknoglobals // ok
+
+type (
+	// Body is implemented by request bodies that encode themselves, returning the data and its content type.
+	Body interface {
+		Encode() ([]byte, string, error)
+	}
+
+	// RawBody is a body sent as is with the given content type, application/octet-stream if not set.
+	RawBody struct {
+		Data        []byte
+		ContentType string
+	}
+
+	// MergePatch is a JSON merge patch (RFC 7386) document, sent as application/merge-patch+json.
+	MergePatch struct {
+		Patch interface{}
+	}
+
+	// JSONPatch is a list of JSON patch (RFC 6902) operations, sent as application/json-patch+json.
+	JSONPatch []PatchOperation
+
+	// PatchOperation is a JSON patch operation.
+	PatchOperation struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		From  string      `json:"from,omitempty"`
+		Value interface{} `json:"value"`
+	}
+
+	// Multipart is a multipart/form-data body containing fields and files.
+	Multipart struct {
+		Fields map[string]string
+		Files  []MultipartFile
+	}
+
+	// MultipartFile is a file in a multipart body, the content type defaults to application/octet-stream.
+	MultipartFile struct {
+		FieldName   string
+		FileName    string
+		ContentType string
+		Content     io.Reader
+	}
+)
+
+// Encode implements Body.
+func (b RawBody) Encode() ([]byte, string, error) {
+	if len(b.ContentType) == 0 {
+		return b.Data, AppOctetStream, nil
+	}
+
+	return b.Data, b.ContentType, nil
+}
+
+// Encode implements Body.
+func (b MergePatch) Encode() ([]byte, string, error) {
+	data, err := json.Marshal(b.Patch)
+
+	return data, AppMergePatch, err
+}
+
+// Encode implements Body.
+func (b JSONPatch) Encode() ([]byte, string, error) {
+	data, err := json.Marshal([]PatchOperation(b))
+
+	return data, AppJSONPatch, err
+}
+
+// MarshalJSON omits the value of operations that do not have one.
+func (p PatchOperation) MarshalJSON() ([]byte, error) {
+	type operation PatchOperation
+
+	if p.Op == patchOpRemove || p.Op == patchOpMove || p.Op == patchOpCopy {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+			From string `json:"from,omitempty"`
+		}{p.Op, p.Path, p.From})
+	}
+
+	return json.Marshal(operation(p))
+}
+
+// Encode implements Body.
+func (b *Multipart) Encode() ([]byte, string, error) {
+	var buf bytes.Buffer
+
+	w := multipart.NewWriter(&buf)
+
+	for name, value := range b.Fields {
+		if err := w.WriteField(name, value); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, file := range b.Files {
+		contentType := file.ContentType
+		if len(contentType) == 0 {
+			contentType = AppOctetStream
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition",
+			fmt.Sprintf(multipartFileKey, quoteEscaper.Replace(file.FieldName), quoteEscaper.Replace(file.FileName)))
+		header.Set(ContentType, contentType)
+
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if _, err := io.Copy(part, file.Content); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+// encodeBody converts a request body to bytes so it can be resent on retries, returning the data and content type.
+// A Body encodes itself, []byte and io.Reader are sent as is, url.Values are form encoded and anything else is sent as JSON.
+// A nil body is sent as no body rather than a JSON null.
+func encodeBody(body interface{}) ([]byte, string, error) {
+	switch b := body.(type) {
+	case nil:
+		return nil, "", nil
+	case Body:
+		return b.Encode()
+	case []byte:
+		return b, AppOctetStream, nil
+	case io.Reader:
+		data, err := ioutil.ReadAll(b)
+
+		return data, AppOctetStream, err
+	case url.Values:
+		return []byte(b.Encode()), AppForm, nil
+	default:
+		data, err := json.Marshal(b)
+
+		return data, AppJSON, err
+	}
+}
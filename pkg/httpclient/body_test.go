@@ -0,0 +1,132 @@
+package httpclient_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+)
+
+// echoServer responds with the request method, content type and body, failing the first failures requests with a 503.
+func echoServer(t *testing.T, failures int32) *httptest.Server {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body, %s", err)
+		}
+
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		fmt.Fprintf(w, "%s|%s|%s", r.Method, r.Header.Get(httpclient.ContentType), body)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func sendBody(t *testing.T, server *httptest.Server, method string, body interface{}, header httpclient.Header) string {
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse url, %s", err)
+	}
+
+	r, err := httpclient.NewReqResp(context.Background(), u, &method, body, header, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create request, %s", err)
+	}
+
+	r.SetRetryPolicy(fastPolicy())
+
+	if err := r.HTTPreq(); err != nil {
+		t.Fatalf("request failed, %s", err)
+	}
+
+	return r.RespBody()
+}
+
+func TestRequestBodies(t *testing.T) {
+	server := echoServer(t, 0)
+
+	tests := []struct {
+		testNum  int
+		method   string
+		body     interface{}
+		header   httpclient.Header
+		expected string
+	}{
+		{1, httpclient.Get, nil, nil, "GET||"},
+		{2, httpclient.Post, map[string]int{"a": 1}, nil, `POST|application/json|{"a":1}`},
+		{3, httpclient.Put, map[string]int{"a": 1}, nil, `PUT|application/json|{"a":1}`},
+		{4, httpclient.Patch, httpclient.MergePatch{Patch: map[string]interface{}{"a": nil}}, nil, `PATCH|application/merge-patch+json|{"a":null}`},
+		{5, httpclient.Patch, httpclient.JSONPatch{{Op: "replace", Path: "/a", Value: 2}, {Op: "remove", Path: "/b"}}, nil,
+			`PATCH|application/json-patch+json|[{"op":"replace","path":"/a","value":2},{"op":"remove","path":"/b"}]`},
+		{6, httpclient.Post, []byte("raw"), nil, "POST|application/octet-stream|raw"},
+		{7, httpclient.Put, strings.NewReader("reader"), httpclient.Header{httpclient.ContentType: "text/plain"}, "PUT|text/plain|reader"},
+		{8, httpclient.Post, url.Values{"a": []string{"1"}, "b": []string{"x y"}}, nil, "POST|application/x-www-form-urlencoded|a=1&b=x+y"},
+		{9, httpclient.Delete, httpclient.RawBody{Data: []byte("<a/>"), ContentType: "application/xml"}, nil, "DELETE|application/xml|<a/>"},
+		{10, httpclient.Post, nil, nil, "POST||"},
+		{11, httpclient.Get, map[string]int{"q": 1}, nil, `GET|application/json|{"q":1}`},
+		{12, httpclient.Delete, map[string]int{"id": 1}, nil, `DELETE|application/json|{"id":1}`},
+	}
+
+	for _, test := range tests {
+		if result := sendBody(t, server, test.method, test.body, test.header); result != test.expected {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %s", test.testNum, test.expected, result)
+		}
+	}
+}
+
+func TestRequestBodyResentOnRetry(t *testing.T) {
+	server := echoServer(t, 2)
+
+	if result := sendBody(t, server, httpclient.Put, strings.NewReader("payload"), nil); result != "PUT|application/octet-stream|payload" {
+		t.Errorf("\nExpected: PUT|application/octet-stream|payload\nGot.....: %s", result)
+	}
+}
+
+func TestMultipartBody(t *testing.T) {
+	body := &httpclient.Multipart{
+		Fields: map[string]string{"name": "report"},
+		Files: []httpclient.MultipartFile{
+			{FieldName: "file", FileName: "report.txt", ContentType: "text/plain", Content: strings.NewReader("contents")},
+			{FieldName: `quoted"field`, FileName: `a "b" \c.txt`, Content: strings.NewReader("quoted")},
+		},
+	}
+
+	data, contentType, err := body.Encode()
+	if err != nil {
+		t.Fatalf("failed to encode multipart body, %s", err)
+	}
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("invalid content type %s, %s", contentType, err)
+	}
+
+	form, err := multipart.NewReader(strings.NewReader(string(data)), params["boundary"]).ReadForm(1024)
+	if err != nil {
+		t.Fatalf("failed to read multipart form, %s", err)
+	}
+
+	if form.Value["name"][0] != "report" || form.File["file"][0].Filename != "report.txt" ||
+		form.File["file"][0].Header.Get(httpclient.ContentType) != "text/plain" ||
+		len(form.File[`quoted"field`]) != 1 || form.File[`quoted"field`][0].Filename != `a "b" \c.txt` {
+		t.Errorf("unexpected multipart form: %+v, %+v", form.Value, form.File)
+	}
+}
@@ -0,0 +1,239 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/paulcarlton-ww/goutils/pkg/logging"
+)
+
+const defaultFailureThreshold = 5
+
+// BreakerClosed, BreakerOpen and BreakerHalfOpen are the circuit breaker states.
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+var ErrorCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a host's circuit breaker.
+type BreakerState int
+
+// String returns the name of the state.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// CircuitOpenError is returned without sending a request when the circuit breaker for the host is open.
+// It wraps ErrorCircuitOpen.
+type CircuitOpenError struct {
+	Host    string
+	RetryAt time.Time // Time after which a trial request will be allowed.
+}
+
+// Error implements the error interface.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: %s, retry after %s", ErrorCircuitOpen, e.Host, e.RetryAt.Format(time.RFC3339))
+}
+
+// Unwrap returns ErrorCircuitOpen.
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrorCircuitOpen
+}
+
+// BreakerOptions holds the settings of a CircuitBreaker.
+type BreakerOptions struct {
+	FailureThreshold int           // Consecutive failures that open the circuit, defaults to five.
+	CoolDown         time.Duration // Time the circuit stays open before trial requests are allowed, defaults to thirty seconds.
+	HalfOpenRequests int           // Concurrent trial requests allowed while half-open, defaults to one.
+	// IsFailure classifies the result of an attempt, leave unset to count errors and 5xx responses as failures.
+	IsFailure func(resp *http.Response, err error) bool
+	// OnStateChange is called when a host's circuit changes state, it must not call the CircuitBreaker's methods.
+	OnStateChange func(host string, from, to BreakerState)
+	Logger        logr.Logger
+}
+
+// BreakerMetrics holds the state and counters of a host's circuit breaker.
+type BreakerMetrics struct {
+	State     BreakerState
+	Failures  int    // Current number of consecutive failures.
+	Requests  uint64 // Attempts allowed.
+	Rejected  uint64 // Attempts rejected while open.
+	Successes uint64
+	Opened    uint64 // Number of times the circuit has opened.
+}
+
+// CircuitBreaker tracks the results of requests to each host, failing requests fast while a host's circuit is open.
+// A circuit opens after FailureThreshold consecutive failures, after CoolDown it is half-open and trial requests are
+// allowed, closing it if they succeed and opening it again if they fail. It is safe for concurrent use.
+type CircuitBreaker struct {
+	opts   BreakerOptions
+	mutex  sync.Mutex
+	hosts  map[string]*hostCircuit
+	logger logr.Logger
+}
+
+type hostCircuit struct {
+	metrics  BreakerMetrics
+	openedAt time.Time
+	inFlight int
+}
+
+// NewCircuitBreaker returns a CircuitBreaker, nil options use the defaults.
+func NewCircuitBreaker(opts *BreakerOptions) *CircuitBreaker {
+	b := &CircuitBreaker{hosts: map[string]*hostCircuit{}}
+
+	if opts != nil {
+		b.opts = *opts
+	}
+
+	if b.opts.FailureThreshold <= 0 {
+		b.opts.FailureThreshold = defaultFailureThreshold
+	}
+
+	if b.opts.CoolDown <= 0 {
+		b.opts.CoolDown = thirty * time.Second
+	}
+
+	if b.opts.HalfOpenRequests <= 0 {
+		b.opts.HalfOpenRequests = one
+	}
+
+	if b.opts.IsFailure == nil {
+		b.opts.IsFailure = IsServerFailure
+	}
+
+	b.logger = b.opts.Logger
+	if b.logger == nil {
+		b.logger = logging.NewLogger("circuitBreaker", &zap.Options{})
+	}
+
+	return b
+}
+
+// IsServerFailure returns true for errors and 5xx responses, it is the default BreakerOptions.IsFailure.
+func IsServerFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return resp != nil && resp.StatusCode >= http.StatusInternalServerError
+}
+
+// State returns the state of the host's circuit.
+func (b *CircuitBreaker) State(host string) BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.circuit(host).metrics.State
+}
+
+// Metrics returns the state and counters of each host's circuit.
+func (b *CircuitBreaker) Metrics() map[string]BreakerMetrics {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	metrics := make(map[string]BreakerMetrics, len(b.hosts))
+	for host, c := range b.hosts {
+		metrics[host] = c.metrics
+	}
+
+	return metrics
+}
+
+// circuit returns the host's circuit, moving an open circuit to half-open once the cool-down has passed.
+func (b *CircuitBreaker) circuit(host string) *hostCircuit {
+	c, ok := b.hosts[host]
+	if !ok {
+		c = &hostCircuit{}
+		b.hosts[host] = c
+	}
+
+	if c.metrics.State == BreakerOpen && time.Since(c.openedAt) >= b.opts.CoolDown {
+		b.transition(host, c, BreakerHalfOpen)
+	}
+
+	return c
+}
+
+// allow returns a *CircuitOpenError if an attempt to the host is not allowed.
+func (b *CircuitBreaker) allow(host string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c := b.circuit(host)
+
+	if c.metrics.State == BreakerOpen || (c.metrics.State == BreakerHalfOpen && c.inFlight >= b.opts.HalfOpenRequests) {
+		c.metrics.Rejected++
+
+		return &CircuitOpenError{Host: host, RetryAt: c.openedAt.Add(b.opts.CoolDown)}
+	}
+
+	c.inFlight++
+	c.metrics.Requests++
+
+	return nil
+}
+
+// record records the result of an attempt allowed by allow. Cancelled attempts are not counted.
+func (b *CircuitBreaker) record(host string, resp *http.Response, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c := b.circuit(host)
+	c.inFlight--
+
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if !b.opts.IsFailure(resp, err) {
+		c.metrics.Successes++
+		c.metrics.Failures = 0
+
+		if c.metrics.State == BreakerHalfOpen {
+			b.transition(host, c, BreakerClosed)
+		}
+
+		return
+	}
+
+	c.metrics.Failures++
+
+	if c.metrics.State == BreakerHalfOpen || (c.metrics.State == BreakerClosed && c.metrics.Failures >= b.opts.FailureThreshold) {
+		c.openedAt = time.Now()
+		c.metrics.Opened++
+		b.transition(host, c, BreakerOpen)
+	}
+}
+
+// transition changes the state of the host's circuit, the mutex must be held.
+func (b *CircuitBreaker) transition(host string, c *hostCircuit, to BreakerState) {
+	from := c.metrics.State
+	c.metrics.State = to
+
+	b.logger.Info("circuit breaker state changed", "host", host, "from", from.String(), "to", to.String(),
+		"failures", c.metrics.Failures)
+
+	if b.opts.OnStateChange != nil {
+		b.opts.OnStateChange(host, from, to)
+	}
+}
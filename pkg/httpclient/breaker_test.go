@@ -0,0 +1,112 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+)
+
+func TestCircuitBreaker(t *testing.T) { // nolint:funlen // ok
+	// The responses to the requests that are not rejected by the breaker.
+	server := mockserver.New(t)
+	expectation := server.Expect(httpclient.Get, "/").Respond(
+		mockserver.Status(http.StatusInternalServerError), mockserver.Status(http.StatusNotFound),
+		mockserver.Status(http.StatusBadGateway), mockserver.Status(http.StatusServiceUnavailable),
+		mockserver.Status(http.StatusInternalServerError), mockserver.Status(http.StatusOK),
+		mockserver.Status(http.StatusInternalServerError),
+	)
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse url, %s", err)
+	}
+
+	host := u.Host
+	transitions := []string{}
+	coolDown := 50 * time.Millisecond
+
+	breaker := httpclient.NewCircuitBreaker(&httpclient.BreakerOptions{
+		FailureThreshold: 2,
+		CoolDown:         coolDown,
+		OnStateChange: func(h string, from, to httpclient.BreakerState) {
+			if h == host {
+				transitions = append(transitions, fmt.Sprintf("%s>%s", from, to))
+			}
+		},
+	})
+
+	client, err := httpclient.NewClient(httpclient.WithCircuitBreaker(breaker), httpclient.WithRetryPolicy(nil))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	tests := []struct {
+		testNum  int
+		wait     time.Duration
+		expected error
+		calls    int
+		state    httpclient.BreakerState
+	}{
+		{1, 0, httpclient.ErrorRequestFailed, 1, httpclient.BreakerClosed},
+		{2, 0, httpclient.ErrorRequestFailed, 2, httpclient.BreakerClosed},
+		{3, 0, httpclient.ErrorRequestFailed, 3, httpclient.BreakerClosed},
+		{4, 0, httpclient.ErrorRequestFailed, 4, httpclient.BreakerOpen},
+		{5, 0, httpclient.ErrorCircuitOpen, 4, httpclient.BreakerOpen},
+		{6, coolDown, httpclient.ErrorRequestFailed, 5, httpclient.BreakerOpen},
+		{7, 0, httpclient.ErrorCircuitOpen, 5, httpclient.BreakerOpen},
+		{8, coolDown, nil, 6, httpclient.BreakerClosed},
+		{9, 0, httpclient.ErrorRequestFailed, 7, httpclient.BreakerClosed},
+	}
+
+	for _, test := range tests {
+		time.Sleep(test.wait)
+
+		_, err := client.Get(context.Background(), server.URL).Do()
+		if !errors.Is(err, test.expected) || (err == nil) != (test.expected == nil) ||
+			expectation.Calls() != test.calls || breaker.State(host) != test.state {
+			t.Errorf("\nTest: %d\nExpected: %v, %d calls, %s\nGot.....: %v, %d calls, %s",
+				test.testNum, test.expected, test.calls, test.state, err, expectation.Calls(), breaker.State(host))
+		}
+	}
+
+	server.Verify()
+
+	expected := "[closed>open open>half-open half-open>open open>half-open half-open>closed]"
+	if fmt.Sprint(transitions) != expected {
+		t.Errorf("\nExpected: %s\nGot.....: %v", expected, transitions)
+	}
+
+	metrics := breaker.Metrics()[host]
+	if metrics.Requests != 7 || metrics.Rejected != 2 || metrics.Opened != 2 || metrics.Successes != 2 || metrics.Failures != 1 {
+		t.Errorf("unexpected metrics: %+v", metrics)
+	}
+}
+
+func TestCircuitBreakerRetries(t *testing.T) {
+	server := mockserver.New(t)
+	expectation := server.Expect(httpclient.Get, "/").Respond(mockserver.Status(http.StatusServiceUnavailable))
+	breaker := httpclient.NewCircuitBreaker(&httpclient.BreakerOptions{FailureThreshold: 2})
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse url, %s", err)
+	}
+
+	r := newGet(t, server.URL)
+	r.SetRetryPolicy(fastPolicy())
+	r.SetCircuitBreaker(breaker)
+
+	var openErr *httpclient.CircuitOpenError
+
+	err = r.HTTPreq()
+	if !errors.As(err, &openErr) || openErr.Host != u.Host || !openErr.RetryAt.After(time.Now()) || expectation.Calls() != 2 {
+		t.Errorf("expected retries to stop when the circuit opens, got %v after %d calls", err, expectation.Calls())
+	}
+}
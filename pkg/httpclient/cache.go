@@ -0,0 +1,648 @@
+package httpclient
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/logging"
+)
+
+const (
+	// DefaultCacheEntries is the number of responses held by the memory storage used if CacheOptions.Storage is not set.
+	DefaultCacheEntries = 1000
+
+	heuristicFraction = 10 // Heuristic freshness is a tenth of the time since the response was last modified.
+	dirMode           = 0o755
+)
+
+// CacheMiss, CacheHit and CacheRevalidated describe how a response was obtained when a cache is used.
+const (
+	CacheMiss CacheStatus = iota
+	CacheHit
+	CacheRevalidated
+)
+
+var (
+	ErrorCache = errors.New("failed to access cache storage")
+
+	// credentialHeaders are the request headers identifying the credentials a response was obtained with.
+	credentialHeaders = []string{"Authorization", "Cookie"} // nolint:gochecknoglobals // ok
+)
+
+func cacheError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrorCache, msg)
+}
+
+// CacheStatus describes how a response was obtained, CacheMiss if it was fetched from the server or no cache is used,
+// CacheHit if it was served from the cache without contacting the server and CacheRevalidated if the cached response
+// was confirmed to be current by a 304 response.
+type CacheStatus int
+
+// String returns the name of the status.
+func (s CacheStatus) String() string {
+	switch s {
+	case CacheMiss:
+		return "miss"
+	case CacheHit:
+		return "hit"
+	case CacheRevalidated:
+		return "revalidated"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+type (
+	// CacheStorage is the interface used by a Cache to store responses, implementations must be safe for concurrent use.
+	CacheStorage interface {
+		// Get returns the data stored for the key, false if there is none.
+		Get(key string) ([]byte, bool, error)
+		// Set stores the data for the key, replacing any existing data.
+		Set(key string, data []byte) error
+		// Delete removes the data stored for the key, if any.
+		Delete(key string) error
+	}
+
+	// CacheOptions holds the settings of a Cache.
+	CacheOptions struct {
+		Storage CacheStorage // Defaults to a MemoryCache holding DefaultCacheEntries responses.
+		// Shared makes the cache behave as a shared cache, it does not store private responses or responses to
+		// requests with an Authorization header unless permitted and it uses the s-maxage directive.
+		Shared bool
+	}
+
+	// Cache is an RFC 7234 cache for GET responses. Fresh responses are served without contacting the server, stale
+	// responses with an ETag or Last-Modified header are revalidated using a conditional request and a 304 response
+	// is replaced by the cached response. Responses are stored when their body is read by HTTPreq, streamed responses
+	// and responses to Range requests are not stored. Responses are stored by URL and the request's Authorization and
+	// Cookie headers, so a response is only used for requests with the same credentials, and the request headers
+	// named by the response's Vary header must match. It is safe for concurrent use and may be shared by clients.
+	Cache struct {
+		storage CacheStorage
+		shared  bool
+	}
+
+	// cacheEntry is a stored response.
+	cacheEntry struct {
+		StatusCode   int               `json:"statusCode"`
+		Header       http.Header       `json:"header"`
+		Body         []byte            `json:"body"`
+		Vary         map[string]string `json:"vary,omitempty"` // Request header values selected by the Vary header.
+		RequestTime  time.Time         `json:"requestTime"`
+		ResponseTime time.Time         `json:"responseTime"`
+	}
+
+	// cacheControl holds the directives of a Cache-Control header.
+	cacheControl map[string]string
+
+	// MemoryCache is a CacheStorage holding a limited number of entries in memory, discarding the least recently
+	// used entries when full.
+	MemoryCache struct {
+		mutex      sync.Mutex
+		maxEntries int
+		entries    map[string]*list.Element
+		lru        *list.List
+	}
+
+	// memoryEntry is an element of the MemoryCache's list.
+	memoryEntry struct {
+		key  string
+		data []byte
+	}
+
+	// DiskCache is a CacheStorage holding entries in files in a directory, entries are not expired.
+	DiskCache struct {
+		dir string
+	}
+)
+
+// NewCache returns a Cache, nil options give a private cache using a MemoryCache.
+func NewCache(opts *CacheOptions) *Cache {
+	c := &Cache{}
+
+	if opts != nil {
+		c.storage = opts.Storage
+		c.shared = opts.Shared
+	}
+
+	if c.storage == nil {
+		c.storage = NewMemoryCache(DefaultCacheEntries)
+	}
+
+	return c
+}
+
+// cacheable returns true if the cache can be used for the request. Requests carrying their own conditional or Range
+// headers are sent as is.
+func (c *Cache) cacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet && !parseCacheControl(req.Header).has("no-store") &&
+		len(req.Header.Get("If-None-Match")) == 0 && len(req.Header.Get("If-Modified-Since")) == 0 &&
+		len(req.Header.Get("Range")) == 0
+}
+
+// cacheKey returns the storage key for the request, its URL followed by a digest of its credentials if it has any.
+func cacheKey(req *http.Request) string {
+	h := sha256.New()
+	found := false
+
+	for _, name := range credentialHeaders {
+		for _, value := range req.Header.Values(name) {
+			fmt.Fprintf(h, "%s: %s\n", name, value)
+
+			found = true
+		}
+	}
+
+	if !found {
+		return req.URL.String()
+	}
+
+	return req.URL.String() + " " + hex.EncodeToString(h.Sum(nil))
+}
+
+// lookup returns the stored response for the request, nil if there is none or it does not match the request's headers.
+func (c *Cache) lookup(req *http.Request) (*cacheEntry, error) {
+	data, ok, err := c.storage.Get(cacheKey(req))
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, cacheError(err.Error())
+	}
+
+	for name, value := range entry.Vary {
+		if req.Header.Get(name) != value {
+			return nil, nil
+		}
+	}
+
+	return entry, nil
+}
+
+// store stores the response to the request.
+func (c *Cache) store(req *http.Request, entry *cacheEntry) error {
+	entry.Vary = map[string]string{}
+
+	for _, value := range entry.Header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); len(name) > 0 {
+				entry.Vary[name] = req.Header.Get(name)
+			}
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return cacheError(err.Error())
+	}
+
+	return c.storage.Set(cacheKey(req), data)
+}
+
+// storable returns true if the response to the request may be stored and can be used for later requests, because it
+// has an explicit freshness lifetime or validators.
+func (c *Cache) storable(req *http.Request, resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+	default:
+		return false
+	}
+
+	cc := parseCacheControl(resp.Header)
+
+	if cc.has("no-store") || resp.Header.Get("Vary") == "*" {
+		return false
+	}
+
+	if c.shared && (cc.has("private") ||
+		len(req.Header.Get("Authorization")) > 0 && !cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate")) {
+		return false
+	}
+
+	return cc.has("max-age") || (c.shared && cc.has("s-maxage")) || len(resp.Header.Get("Expires")) > 0 ||
+		len(resp.Header.Get("ETag")) > 0 || len(resp.Header.Get("Last-Modified")) > 0
+}
+
+// invalidate removes the stored responses for the request's URL, without credentials and with the request's
+// credentials, if a request using an unsafe method succeeded.
+func (c *Cache) invalidate(req *http.Request, resp *http.Response) error {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return nil
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil
+	}
+
+	if err := c.storage.Delete(req.URL.String()); err != nil {
+		return err
+	}
+
+	return c.storage.Delete(cacheKey(req))
+}
+
+// fresh returns true if the entry can be used for the request without revalidation.
+func (c *Cache) fresh(entry *cacheEntry, req *http.Request, now time.Time) bool {
+	reqCC := parseCacheControl(req.Header)
+	respCC := parseCacheControl(entry.Header)
+
+	if reqCC.has("no-cache") || respCC.has("no-cache") ||
+		(len(req.Header.Get("Cache-Control")) == 0 && req.Header.Get("Pragma") == "no-cache") {
+		return false
+	}
+
+	lifetime := c.lifetime(entry, respCC)
+	age := entry.age(now)
+
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+
+	if minFresh, ok := reqCC.seconds("min-fresh"); ok {
+		age += minFresh
+	}
+
+	if age < lifetime {
+		return true
+	}
+
+	if respCC.has("must-revalidate") || (c.shared && respCC.has("proxy-revalidate")) || !reqCC.has("max-stale") {
+		return false
+	}
+
+	maxStale, ok := reqCC.seconds("max-stale")
+
+	return !ok || age-lifetime <= maxStale
+}
+
+// lifetime returns the freshness lifetime of the entry, using a heuristic based on the Last-Modified header if no
+// explicit lifetime is given.
+func (c *Cache) lifetime(entry *cacheEntry, cc cacheControl) time.Duration {
+	if maxAge, ok := cc.seconds("s-maxage"); ok && c.shared {
+		return maxAge
+	}
+
+	if maxAge, ok := cc.seconds("max-age"); ok {
+		return maxAge
+	}
+
+	date := entry.date()
+
+	if expires := entry.Header.Get("Expires"); len(expires) > 0 {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+
+		return t.Sub(date)
+	}
+
+	if modified, err := http.ParseTime(entry.Header.Get("Last-Modified")); err == nil && date.After(modified) {
+		return date.Sub(modified) / heuristicFraction
+	}
+
+	return 0
+}
+
+// newCacheEntry returns an entry for a response.
+func newCacheEntry(resp *http.Response, body []byte, requestTime, responseTime time.Time) *cacheEntry {
+	return &cacheEntry{
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+}
+
+// date returns the time the response was generated, the time it was received if it has no valid Date header.
+func (e *cacheEntry) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return date
+	}
+
+	return e.ResponseTime
+}
+
+// age returns the current age of the entry, see RFC 7234 section 4.2.3.
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparent := e.ResponseTime.Sub(e.date())
+	if apparent < 0 {
+		apparent = 0
+	}
+
+	ageValue, err := strconv.Atoi(e.Header.Get("Age"))
+	if err != nil || ageValue < 0 {
+		ageValue = 0
+	}
+
+	corrected := time.Duration(ageValue)*time.Second + e.ResponseTime.Sub(e.RequestTime)
+	if corrected > apparent {
+		apparent = corrected
+	}
+
+	return apparent + now.Sub(e.ResponseTime)
+}
+
+// conditional adds the entry's validators to a request.
+func (e *cacheEntry) conditional(req *http.Request) {
+	if etag := e.Header.Get("ETag"); len(etag) > 0 {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	if modified := e.Header.Get("Last-Modified"); len(modified) > 0 {
+		req.Header.Set("If-Modified-Since", modified)
+	}
+}
+
+// update updates the entry with the headers of a 304 response.
+func (e *cacheEntry) update(header http.Header, requestTime, responseTime time.Time) {
+	for name, values := range header {
+		if name != "Content-Length" {
+			e.Header[name] = values
+		}
+	}
+
+	e.RequestTime, e.ResponseTime = requestTime, responseTime
+}
+
+// response returns the entry as a response to the request.
+func (e *cacheEntry) response(req *http.Request, now time.Time) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(e.age(now).Seconds())))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// parseCacheControl returns the directives of the Cache-Control headers.
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); len(name) > 0 {
+				cc[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+			}
+		}
+	}
+
+	return cc
+}
+
+// has returns true if the directive is present.
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+
+	return ok
+}
+
+// seconds returns the value of a directive giving a number of seconds, an invalid value is treated as zero.
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	value, ok := cc[name]
+	if !ok || len(value) == 0 {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, true
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+// NewMemoryCache returns a MemoryCache holding up to maxEntries entries, DefaultCacheEntries if maxEntries is not
+// positive.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheEntries
+	}
+
+	return &MemoryCache{maxEntries: maxEntries, entries: map[string]*list.Element{}, lru: list.New()}
+}
+
+// Get implements CacheStorage.
+func (m *MemoryCache) Get(key string) ([]byte, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	m.lru.MoveToFront(element)
+
+	return element.Value.(*memoryEntry).data, true, nil
+}
+
+// Set implements CacheStorage.
+func (m *MemoryCache) Set(key string, data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if element, ok := m.entries[key]; ok {
+		element.Value.(*memoryEntry).data = data
+		m.lru.MoveToFront(element)
+
+		return nil
+	}
+
+	m.entries[key] = m.lru.PushFront(&memoryEntry{key: key, data: data})
+
+	if m.lru.Len() > m.maxEntries {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).key)
+	}
+
+	return nil
+}
+
+// Delete implements CacheStorage.
+func (m *MemoryCache) Delete(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if element, ok := m.entries[key]; ok {
+		m.lru.Remove(element)
+		delete(m.entries, key)
+	}
+
+	return nil
+}
+
+// Len returns the number of entries held.
+func (m *MemoryCache) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.lru.Len()
+}
+
+// NewDiskCache returns a DiskCache storing entries in the directory, creating it if required.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return nil, cacheError(err.Error())
+	}
+
+	return &DiskCache{dir: dir}, nil
+}
+
+// Get implements CacheStorage.
+func (d *DiskCache) Get(key string) ([]byte, bool, error) {
+	data, err := ioutil.ReadFile(d.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+
+		return nil, false, cacheError(err.Error())
+	}
+
+	return data, true, nil
+}
+
+// Set implements CacheStorage, the entry is written to a temporary file which is renamed so readers never see a
+// partially written entry.
+func (d *DiskCache) Set(key string, data []byte) error {
+	file, err := ioutil.TempFile(d.dir, ".entry-")
+	if err != nil {
+		return cacheError(err.Error())
+	}
+
+	defer os.Remove(file.Name()) // nolint:errcheck // ok
+
+	if _, err := file.Write(data); err != nil {
+		file.Close() // nolint:errcheck,gosec // ok
+
+		return cacheError(err.Error())
+	}
+
+	if err := file.Close(); err != nil {
+		return cacheError(err.Error())
+	}
+
+	if err := os.Rename(file.Name(), d.path(key)); err != nil {
+		return cacheError(err.Error())
+	}
+
+	return nil
+}
+
+// Delete implements CacheStorage.
+func (d *DiskCache) Delete(key string) error {
+	if err := os.Remove(d.path(key)); err != nil && !os.IsNotExist(err) {
+		return cacheError(err.Error())
+	}
+
+	return nil
+}
+
+// path returns the name of the file holding the key's entry.
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
+
+// SetCache sets the Cache used for the request, nil disables caching.
+func (r *reqResp) SetCache(cache *Cache) {
+	r.cache = cache
+}
+
+// cacheLookup finds the cached response for the request, returning true if it is fresh and has been used as the
+// response. A stale response is kept in reqResp.cacheEntry for revalidation.
+func (r *reqResp) cacheLookup(req *http.Request, buffer bool) bool {
+	if r.cacheable = r.cache.cacheable(req); !r.cacheable {
+		return false
+	}
+
+	entry, err := r.cache.lookup(req)
+	if err != nil {
+		r.logger.Error(err, "failed to read cached response", "url", r.redactedURL(r.url))
+
+		return false
+	}
+
+	r.cacheEntry = entry
+
+	now := time.Now()
+	if entry == nil || !r.cache.fresh(entry, req, now) {
+		return false
+	}
+
+	r.resp = entry.response(req, now)
+	r.cacheStatus = CacheHit
+
+	if buffer {
+		text := string(entry.Body)
+		r.respText = &text
+	}
+
+	r.logger.V(logging.GetTraceLevel()).Info("response served from cache", "url", r.redactedURL(r.url), "status", entry.StatusCode)
+
+	return true
+}
+
+// updateCache replaces a 304 response to a revalidation request with the cached response, stores a cacheable
+// response and removes the cached response for a URL updated by an unsafe request.
+func (r *reqResp) updateCache(req *http.Request, requestTime time.Time, buffer bool) {
+	now := time.Now()
+
+	var err error
+
+	switch {
+	case r.cacheEntry != nil && r.resp.StatusCode == http.StatusNotModified:
+		if !buffer {
+			r.discardBody()
+		}
+
+		r.cacheEntry.update(r.resp.Header, requestTime, now)
+		err = r.cache.store(req, r.cacheEntry)
+		r.resp = r.cacheEntry.response(req, now)
+		r.cacheStatus = CacheRevalidated
+
+		if buffer {
+			text := string(r.cacheEntry.Body)
+			r.respText = &text
+		}
+	case buffer && r.cacheable && r.cache.storable(req, r.resp):
+		err = r.cache.store(req, newCacheEntry(r.resp, []byte(*r.respText), requestTime, now))
+	default:
+		err = r.cache.invalidate(req, r.resp)
+	}
+
+	if err != nil {
+		r.logger.Error(err, "failed to update cache", "url", r.redactedURL(r.url))
+	}
+}
@@ -0,0 +1,285 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+)
+
+// cacheResponse returns a response with the body and the headers given as name, value pairs.
+func cacheResponse(status int, body string, header ...string) mockserver.Response {
+	response := mockserver.Text(status, body)
+	for i := 0; i+1 < len(header); i += 2 {
+		response.Header.Set(header[i], header[i+1])
+	}
+
+	return response
+}
+
+// freshResponses returns responses with the bodies in turn that can be cached for a minute.
+func freshResponses(bodies ...string) []mockserver.Response {
+	responses := make([]mockserver.Response, len(bodies))
+	for i, body := range bodies {
+		responses[i] = cacheResponse(http.StatusOK, body, "Cache-Control", "max-age=60")
+	}
+
+	return responses
+}
+
+func TestCache(t *testing.T) { // nolint:funlen // ok
+	type request struct {
+		method string
+		path   string
+		header httpclient.Header
+		body   string
+		status httpclient.CacheStatus
+	}
+
+	modified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	// Each test scripts the responses to the requests not served from the cache, bodies are numbered in the order
+	// the requests reach the server.
+	tests := []struct {
+		testNum  int
+		opts     *httpclient.CacheOptions
+		expect   func(server *mockserver.Server)
+		requests []request
+	}{
+		{1, nil, func(server *mockserver.Server) {
+			server.Expect(httpclient.Get, "/fresh").Respond(freshResponses("1", "2", "3")...)
+		}, []request{
+			{"GET", "/fresh", nil, "1", httpclient.CacheMiss},
+			{"GET", "/fresh", nil, "1", httpclient.CacheHit},
+			{"GET", "/fresh", httpclient.Header{"Cache-Control": "no-cache"}, "2", httpclient.CacheMiss},
+			{"GET", "/fresh", httpclient.Header{"Cache-Control": "no-store"}, "3", httpclient.CacheMiss},
+			{"GET", "/fresh", nil, "2", httpclient.CacheHit},
+		}},
+		{2, nil, func(server *mockserver.Server) {
+			// The resource is updated after the first revalidation so the second gets the new version.
+			server.Expect(httpclient.Get, "/etag").Header("If-None-Match", `"v0"`).Respond(
+				cacheResponse(http.StatusNotModified, "", "Cache-Control", "no-cache", "ETag", `"v0"`),
+				cacheResponse(http.StatusOK, "4", "Cache-Control", "no-cache", "ETag", `"v1"`),
+			)
+			server.Expect(httpclient.Get, "/etag").Header("If-None-Match", `"v1"`).Respond(
+				cacheResponse(http.StatusNotModified, "", "Cache-Control", "no-cache", "ETag", `"v1"`))
+			server.Expect(httpclient.Get, "/etag").Respond(
+				cacheResponse(http.StatusOK, "1", "Cache-Control", "no-cache", "ETag", `"v0"`))
+			server.Expect(httpclient.Put, "/update").Respond(mockserver.Text(http.StatusOK, "3"))
+		}, []request{
+			{"GET", "/etag", nil, "1", httpclient.CacheMiss},
+			{"GET", "/etag", nil, "1", httpclient.CacheRevalidated},
+			{"PUT", "/update", nil, "3", httpclient.CacheMiss},
+			{"GET", "/etag", nil, "4", httpclient.CacheMiss},
+			{"GET", "/etag", nil, "4", httpclient.CacheRevalidated},
+		}},
+		{3, nil, func(server *mockserver.Server) {
+			server.Expect(httpclient.Get, "/modified").Header("If-Modified-Since", modified).Respond(
+				cacheResponse(http.StatusNotModified, "", "Cache-Control", "max-age=0", "Last-Modified", modified))
+			server.Expect(httpclient.Get, "/modified").Respond(
+				cacheResponse(http.StatusOK, "1", "Cache-Control", "max-age=0", "Last-Modified", modified))
+		}, []request{
+			{"GET", "/modified", nil, "1", httpclient.CacheMiss},
+			{"GET", "/modified", nil, "1", httpclient.CacheRevalidated},
+		}},
+		{4, nil, func(server *mockserver.Server) {
+			server.Expect(httpclient.Get, "/private").Respond(
+				cacheResponse(http.StatusOK, "1", "Cache-Control", "private, max-age=60"))
+			server.Expect(httpclient.Get, "/nostore").Respond(
+				cacheResponse(http.StatusOK, "2", "Cache-Control", "no-store"),
+				cacheResponse(http.StatusOK, "3", "Cache-Control", "no-store"),
+			)
+		}, []request{
+			{"GET", "/private", nil, "1", httpclient.CacheMiss},
+			{"GET", "/private", nil, "1", httpclient.CacheHit},
+			{"GET", "/nostore", nil, "2", httpclient.CacheMiss},
+			{"GET", "/nostore", nil, "3", httpclient.CacheMiss},
+		}},
+		{5, &httpclient.CacheOptions{Shared: true}, func(server *mockserver.Server) {
+			server.Expect(httpclient.Get, "/private").Respond(
+				cacheResponse(http.StatusOK, "1", "Cache-Control", "private, max-age=60"),
+				cacheResponse(http.StatusOK, "2", "Cache-Control", "private, max-age=60"),
+			)
+		}, []request{
+			{"GET", "/private", nil, "1", httpclient.CacheMiss},
+			{"GET", "/private", nil, "2", httpclient.CacheMiss},
+		}},
+		{6, nil, func(server *mockserver.Server) {
+			server.Expect(httpclient.Get, "/vary").Respond(
+				cacheResponse(http.StatusOK, "1", "Cache-Control", "max-age=60", "Vary", "Accept"),
+				cacheResponse(http.StatusOK, "2", "Cache-Control", "max-age=60", "Vary", "Accept"),
+			)
+		}, []request{
+			{"GET", "/vary", httpclient.Header{"Accept": "text/plain"}, "1", httpclient.CacheMiss},
+			{"GET", "/vary", httpclient.Header{"Accept": "text/plain"}, "1", httpclient.CacheHit},
+			{"GET", "/vary", httpclient.Header{"Accept": "application/json"}, "2", httpclient.CacheMiss},
+		}},
+		{7, nil, func(server *mockserver.Server) {
+			server.Expect("", "/fresh").Respond(freshResponses("1", "2", "3")...)
+		}, []request{
+			{"GET", "/fresh", nil, "1", httpclient.CacheMiss},
+			{"DELETE", "/fresh", nil, "2", httpclient.CacheMiss},
+			{"GET", "/fresh", nil, "3", httpclient.CacheMiss},
+		}},
+		{8, nil, func(server *mockserver.Server) {
+			server.Expect(httpclient.Get, "/missing").Respond(
+				cacheResponse(http.StatusNotFound, "404 page not found\n", "Cache-Control", "max-age=60"))
+		}, []request{
+			{"GET", "/missing", nil, "404 page not found\n", httpclient.CacheMiss},
+			{"GET", "/missing", nil, "404 page not found\n", httpclient.CacheHit},
+		}},
+		{9, nil, func(server *mockserver.Server) {
+			server.Expect("", "/fresh").Respond(freshResponses("1", "2", "3", "4", "5", "6", "7")...)
+		}, []request{
+			{"GET", "/fresh", httpclient.Header{"Authorization": "Bearer a"}, "1", httpclient.CacheMiss},
+			{"GET", "/fresh", httpclient.Header{"Authorization": "Bearer a"}, "1", httpclient.CacheHit},
+			{"GET", "/fresh", httpclient.Header{"Authorization": "Bearer b"}, "2", httpclient.CacheMiss},
+			{"GET", "/fresh", httpclient.Header{"Cookie": "session=a"}, "3", httpclient.CacheMiss},
+			{"GET", "/fresh", nil, "4", httpclient.CacheMiss},
+			{"GET", "/fresh", httpclient.Header{"Authorization": "Bearer b"}, "2", httpclient.CacheHit},
+			{"DELETE", "/fresh", httpclient.Header{"Authorization": "Bearer b"}, "5", httpclient.CacheMiss},
+			{"GET", "/fresh", httpclient.Header{"Authorization": "Bearer b"}, "6", httpclient.CacheMiss},
+			{"GET", "/fresh", nil, "7", httpclient.CacheMiss},
+		}},
+		{10, nil, func(server *mockserver.Server) {
+			server.Expect(httpclient.Get, "/fresh").Respond(freshResponses("1", "2")...)
+			server.Expect(httpclient.Get, "/vary").Respond(
+				cacheResponse(http.StatusOK, "3", "Cache-Control", "max-age=60", "Vary", "Accept"),
+				cacheResponse(http.StatusOK, "4", "Cache-Control", "max-age=60", "Vary", "Accept"),
+			)
+		}, []request{
+			{"GET", "/fresh", nil, "1", httpclient.CacheMiss},
+			{"GET", "/fresh", httpclient.Header{"Range": "bytes=0-"}, "2", httpclient.CacheMiss},
+			{"GET", "/fresh", nil, "1", httpclient.CacheHit},
+			{"GET", "/vary", httpclient.Header{"Range": "bytes=0-"}, "3", httpclient.CacheMiss},
+			{"GET", "/vary", nil, "4", httpclient.CacheMiss},
+		}},
+	}
+
+	for _, test := range tests {
+		server := mockserver.New(t)
+		test.expect(server)
+
+		client, err := httpclient.NewClient(httpclient.WithBaseURL(server.URL), httpclient.WithRetryPolicy(nil),
+			httpclient.WithStatusCheck(func(int) bool { return true }), httpclient.WithCache(httpclient.NewCache(test.opts)))
+		if err != nil {
+			t.Fatalf("failed to create client, %s", err)
+		}
+
+		for i, req := range test.requests {
+			b := client.NewRequest(context.Background(), req.method, req.path)
+			for name, value := range req.header {
+				b.Header(name, value)
+			}
+
+			r, err := b.Do()
+			if err != nil || r.RespBody() != req.body || r.Metadata().Cache != req.status {
+				t.Errorf("\nTest: %d, request %d\nExpected: %q, %s\nGot.....: %q, %s, %v",
+					test.testNum, i+1, req.body, req.status, r.RespBody(), r.Metadata().Cache, err)
+			}
+		}
+
+		server.Verify()
+	}
+}
+
+func TestCacheStream(t *testing.T) {
+	server := mockserver.New(t)
+	server.Expect(httpclient.Get, "/fresh").Respond(freshResponses("1")...)
+	cache := httpclient.NewCache(nil)
+
+	client, err := httpclient.NewClient(httpclient.WithRetryPolicy(nil), httpclient.WithCache(cache))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	if _, err := client.Get(context.Background(), server.URL+"/fresh").Do(); err != nil {
+		t.Fatalf("request failed, %s", err)
+	}
+
+	r, err := client.Get(context.Background(), server.URL+"/fresh").Build()
+	if err != nil {
+		t.Fatalf("failed to build request, %s", err)
+	}
+
+	body, err := httpclient.Stream(r, nil)
+	if err != nil {
+		t.Fatalf("failed to stream response, %s", err)
+	}
+
+	defer body.Close()
+
+	var text string
+	if _, err := fmt.Fscan(body, &text); err != nil || text != "1" || r.Metadata().Cache != httpclient.CacheHit {
+		t.Errorf("expected cached response to be streamed, got %q, %s, %v", text, r.Metadata().Cache, err)
+	}
+
+	if age := r.RespHeader().Get("Age"); len(age) == 0 {
+		t.Errorf("expected cached response to have an Age header")
+	}
+}
+
+func TestCacheStorage(t *testing.T) {
+	disk, err := httpclient.NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create disk cache, %s", err)
+	}
+
+	tests := []struct {
+		testNum  int
+		storage  httpclient.CacheStorage
+		expected string
+	}{
+		{1, httpclient.NewMemoryCache(2), "a=,false b=b,true c=,false "},
+		{2, disk, "a=a,true b=b,true c=,false "},
+	}
+
+	for _, test := range tests {
+		for _, key := range []string{"a", "b", "c"} {
+			if err := test.storage.Set(key, []byte(key)); err != nil {
+				t.Errorf("\nTest: %d\nfailed to set %s, %s", test.testNum, key, err)
+			}
+		}
+
+		if err := test.storage.Delete("c"); err != nil {
+			t.Errorf("\nTest: %d\nfailed to delete, %s", test.testNum, err)
+		}
+
+		got := ""
+
+		for _, key := range []string{"a", "b", "c"} {
+			data, ok, err := test.storage.Get(key)
+			if err != nil {
+				t.Errorf("\nTest: %d\nfailed to get %s, %s", test.testNum, key, err)
+			}
+
+			got += fmt.Sprintf("%s=%s,%t ", key, data, ok)
+		}
+
+		if got != test.expected {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %s", test.testNum, test.expected, got)
+		}
+	}
+
+	if _, err := httpclient.NewDiskCache("/dev/null/cache"); !errors.Is(err, httpclient.ErrorCache) {
+		t.Errorf("expected cache error, got %v", err)
+	}
+}
+
+func TestMemoryCacheLRU(t *testing.T) {
+	m := httpclient.NewMemoryCache(2)
+
+	m.Set("a", []byte("a")) // nolint:errcheck // ok
+	m.Set("b", []byte("b")) // nolint:errcheck // ok
+	m.Get("a")              // nolint:errcheck // ok
+	m.Set("c", []byte("c")) // nolint:errcheck // ok
+
+	if _, ok, _ := m.Get("b"); ok || m.Len() != 2 {
+		t.Errorf("expected least recently used entry to be discarded, %d entries", m.Len())
+	}
+}
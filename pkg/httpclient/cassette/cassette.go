@@ -0,0 +1,455 @@
+// Package cassette provides HTTP transports that record interactions to cassette files and replay them, so code
+// using httpclient can be tested offline against real responses.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+)
+
+const (
+	base64Encoding = "base64"
+	contentLength  = "Content-Length"
+	fileMode       = 0o600
+	dirMode        = 0o755
+)
+
+var (
+	ErrorUnmatchedRequest = errors.New("no recorded interaction matches request")
+	ErrorCassette         = errors.New("failed to access cassette")
+)
+
+func unmatchedRequestError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrorUnmatchedRequest, msg)
+}
+
+func cassetteError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrorCassette, msg)
+}
+
+type (
+	// Cassette holds recorded interactions.
+	Cassette struct {
+		Interactions []*Interaction `json:"interactions"`
+	}
+
+	// Interaction is a recorded request and its response.
+	Interaction struct {
+		Request  Request  `json:"request"`
+		Response Response `json:"response"`
+	}
+
+	// Request is a recorded request.
+	Request struct {
+		Method string      `json:"method"`
+		URL    string      `json:"url"`
+		Header http.Header `json:"header,omitempty"`
+		Body   Body        `json:"body,omitempty"`
+	}
+
+	// Response is a recorded response.
+	Response struct {
+		StatusCode int         `json:"statusCode"`
+		Header     http.Header `json:"header,omitempty"`
+		Body       Body        `json:"body,omitempty"`
+	}
+
+	// Body is a recorded body, it is stored as text if it is valid UTF-8 and base64 encoded otherwise.
+	Body []byte
+
+	// Matcher returns true if a request, with its body read into body, matches a recorded request.
+	Matcher func(req *http.Request, body []byte, recorded *Request) bool
+
+	// Options holds the settings of a Recorder or Replayer.
+	Options struct {
+		// Transport used to send requests when recording, defaults to http.DefaultTransport.
+		Transport http.RoundTripper
+		// Matcher used to find the interaction for a request when replaying, defaults to MatchMethodURL.
+		Matcher Matcher
+		// Redact controls the headers, JSON and form fields and URL query parameters redacted when recording, sensitive
+		// values are always redacted. Request URLs and bodies are redacted the same way before being matched when
+		// replaying.
+		Redact *httpclient.LogOptions
+	}
+
+	// Recorder is an http.RoundTripper that sends requests using another transport and records the interactions.
+	// Call Save to write them to the cassette file.
+	Recorder struct {
+		path     string
+		opts     Options
+		mutex    sync.Mutex
+		cassette *Cassette
+	}
+
+	// Replayer is an http.RoundTripper that responds to requests with the responses recorded in a cassette file
+	// without sending them. Each interaction is used once, in the order recorded.
+	Replayer struct {
+		opts     Options
+		mutex    sync.Mutex
+		cassette *Cassette
+		used     []bool
+	}
+)
+
+// bodyEncoding is the JSON form of a body that is not valid UTF-8.
+type bodyEncoding struct {
+	Encoding string `json:"encoding"`
+	Data     string `json:"data"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+
+	return json.Marshal(bodyEncoding{Encoding: base64Encoding, Data: base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = Body(text)
+
+		return nil
+	}
+
+	var encoded bodyEncoding
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	if encoded.Encoding != base64Encoding {
+		return cassetteError(fmt.Sprintf("unsupported body encoding %q", encoded.Encoding))
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Data)
+	if err != nil {
+		return err
+	}
+
+	*b = decoded
+
+	return nil
+}
+
+// Load reads a cassette file.
+func Load(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, cassetteError(err.Error())
+	}
+
+	cassette := &Cassette{}
+	if err := json.Unmarshal(data, cassette); err != nil {
+		return nil, cassetteError(fmt.Sprintf("%s: %s", path, err))
+	}
+
+	return cassette, nil
+}
+
+// Save writes the cassette to a file, creating its directory if required.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return cassetteError(err.Error())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return cassetteError(err.Error())
+	}
+
+	if err := ioutil.WriteFile(path, data, fileMode); err != nil {
+		return cassetteError(err.Error())
+	}
+
+	return nil
+}
+
+// MatchMethodURL matches requests with the same method and URL, it is the default Matcher.
+func MatchMethodURL(req *http.Request, _ []byte, recorded *Request) bool {
+	return req.Method == recorded.Method && req.URL.String() == recorded.URL
+}
+
+// MatchBody matches requests with the same body.
+func MatchBody(_ *http.Request, body []byte, recorded *Request) bool {
+	return bytes.Equal(body, recorded.Body)
+}
+
+// MatchHeaders returns a Matcher matching requests with the same values for the named headers.
+func MatchHeaders(names ...string) Matcher {
+	return func(req *http.Request, _ []byte, recorded *Request) bool {
+		for _, name := range names {
+			if strings.Join(req.Header.Values(name), ",") != strings.Join(recorded.Header.Values(name), ",") {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// MatchAll returns a Matcher matching requests matched by all the matchers.
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(req *http.Request, body []byte, recorded *Request) bool {
+		for _, matcher := range matchers {
+			if !matcher(req, body, recorded) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// defaults returns the options with defaults set.
+func defaults(opts *Options) Options {
+	result := Options{}
+	if opts != nil {
+		result = *opts
+	}
+
+	if result.Transport == nil {
+		result.Transport = http.DefaultTransport
+	}
+
+	if result.Matcher == nil {
+		result.Matcher = MatchMethodURL
+	}
+
+	if result.Redact == nil {
+		result.Redact = &httpclient.LogOptions{}
+	}
+
+	return result
+}
+
+// redactBody redacts a JSON or form encoded body.
+func redactBody(opts *Options, header http.Header, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	contentType := header.Get(httpclient.ContentType)
+
+	switch {
+	case strings.Contains(contentType, "json"):
+		return opts.Redact.RedactJSON(body)
+	case strings.HasPrefix(contentType, httpclient.AppForm):
+		return opts.Redact.RedactForm(body)
+	}
+
+	return body
+}
+
+// readBody reads and replaces a request or response body so it can still be read by the caller.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := (*body).Close(); err != nil {
+		return nil, err
+	}
+
+	*body = ioutil.NopCloser(bytes.NewReader(data))
+
+	return data, nil
+}
+
+// cloneRequest returns a copy of the request with its body read into memory, so the body can be recorded or matched
+// without modifying the caller's request. As allowed for an http.RoundTripper the caller's body is closed, a fresh
+// body from GetBody is read when available so it is not consumed.
+func cloneRequest(req *http.Request) (*http.Request, []byte, error) {
+	clone := req.Clone(req.Context())
+
+	if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		req.Body.Close() // nolint:errcheck,gosec // ok
+
+		clone.Body = body
+	}
+
+	data, err := readBody(&clone.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if data != nil {
+		clone.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+
+	return clone, data, nil
+}
+
+// recordHeader returns a redacted header to record with a body, setting any Content-Length to the recorded body's.
+func recordHeader(opts *Options, header http.Header, body []byte) http.Header {
+	result := opts.Redact.RedactHeader(header)
+	if len(result.Get(contentLength)) > 0 {
+		result.Set(contentLength, strconv.Itoa(len(body)))
+	}
+
+	return result
+}
+
+// NewRecorder returns a Recorder that will save interactions to the cassette file at path.
+func NewRecorder(path string, opts *Options) *Recorder {
+	return &Recorder{path: path, opts: defaults(opts), cassette: &Cassette{}}
+}
+
+// RoundTrip implements http.RoundTripper, sending the request and recording the interaction.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, reqBody, err := cloneRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.opts.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	reqBody = redactBody(&r.opts, req.Header, reqBody)
+	respBody = redactBody(&r.opts, resp.Header, respBody)
+
+	interaction := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    r.opts.Redact.RedactURL(req.URL).String(),
+			Header: recordHeader(&r.opts, req.Header, reqBody),
+			Body:   reqBody,
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     recordHeader(&r.opts, resp.Header, respBody),
+			Body:       respBody,
+		},
+	}
+
+	r.mutex.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mutex.Unlock()
+
+	return resp, nil
+}
+
+// Interactions returns the interactions recorded so far.
+func (r *Recorder) Interactions() []*Interaction {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]*Interaction{}, r.cassette.Interactions...)
+}
+
+// Save writes the recorded interactions to the cassette file.
+func (r *Recorder) Save() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.cassette.Save(r.path)
+}
+
+// NewReplayer returns a Replayer serving the interactions in the cassette file at path.
+func NewReplayer(path string, opts *Options) (*Replayer, error) {
+	cassette, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Replayer{opts: defaults(opts), cassette: cassette, used: make([]bool, len(cassette.Interactions))}, nil
+}
+
+// RoundTrip implements http.RoundTripper, returning the response of the first unused interaction matching the
+// request or an error wrapping ErrorUnmatchedRequest if there is none.
+func (p *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Match a copy of the request with its URL redacted as it would have been when recorded.
+	match, body, err := cloneRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body = redactBody(&p.opts, req.Header, body)
+	match.URL = p.opts.Redact.RedactURL(req.URL)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i, interaction := range p.cassette.Interactions {
+		if p.used[i] || !p.opts.Matcher(match, body, &interaction.Request) {
+			continue
+		}
+
+		p.used[i] = true
+
+		return interaction.Response.httpResponse(req), nil
+	}
+
+	return nil, unmatchedRequestError(fmt.Sprintf("%s %s", req.Method, req.URL))
+}
+
+// Unused returns the interactions that have not been replayed, so tests can check all expected requests were made.
+func (p *Replayer) Unused() []*Interaction {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	unused := []*Interaction{}
+
+	for i, interaction := range p.cassette.Interactions {
+		if !p.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+
+	return unused
+}
+
+// httpResponse returns the recorded response as a response to the request.
+func (r *Response) httpResponse(req *http.Request) *http.Response {
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,224 @@
+package cassette_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/cassette"
+)
+
+type call struct {
+	method string
+	path   string
+	body   interface{}
+}
+
+// apiServer responds with the request details and a token, or a binary payload for /binary.
+func apiServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/binary" {
+			w.Header().Set(httpclient.ContentType, httpclient.AppOctetStream)
+			w.Write([]byte{0xff, 0xfe, 0x00, 0x01}) // nolint:errcheck // ok
+
+			return
+		}
+
+		body := map[string]string{}
+		if r.Header.Get(httpclient.ContentType) == httpclient.AppForm {
+			if err := r.ParseForm(); err != nil {
+				t.Errorf("failed to parse form, %s", err)
+			}
+
+			body["name"] = r.PostForm.Get("client_id")
+		} else if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode request body, %s", err)
+			}
+		}
+
+		w.Header().Set(httpclient.ContentType, httpclient.AppJSON)
+		w.Header().Set("Set-Cookie", "session=abc123")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"method":%q,"name":%q,"path":%q,"token":"tok-456"}`, r.Method, body["name"], r.URL.Path)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func send(t *testing.T, transport http.RoundTripper, baseURL string, calls []call) []string {
+	client, err := httpclient.NewClient(httpclient.WithTransport(transport), httpclient.WithBaseURL(baseURL),
+		httpclient.WithRetryPolicy(nil), httpclient.WithAuth(httpclient.BearerToken("secret-token")))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	results := []string{}
+
+	for _, c := range calls {
+		r, err := client.NewRequest(context.Background(), c.method, c.path).Body(c.body).Do()
+		if err != nil {
+			results = append(results, err.Error())
+
+			continue
+		}
+
+		results = append(results, fmt.Sprintf("%d %s %q", r.ResponseCode(), r.RespHeader().Get(httpclient.ContentType), r.RespBody()))
+	}
+
+	return results
+}
+
+func TestRecordReplay(t *testing.T) {
+	server := apiServer(t)
+	path := filepath.Join(t.TempDir(), "fixtures", "api.json")
+
+	calls := []call{
+		{httpclient.Get, "items?page=1&api_key=qs-key", nil},
+		{httpclient.Post, "items", map[string]string{"name": "a", "password": "hunter2"}},
+		{httpclient.Post, "items", map[string]string{"name": "b"}},
+		{httpclient.Get, "binary", nil},
+		{httpclient.Post, "token", url.Values{"client_id": {"c"}, "client_secret": {"form-secret"}, "password": {"form-pass"}}},
+	}
+
+	recorder := cassette.NewRecorder(path, nil)
+	recorded := send(t, recorder, server.URL, calls)
+
+	if len(recorder.Interactions()) != len(calls) {
+		t.Fatalf("expected %d interactions, got %d", len(calls), len(recorder.Interactions()))
+	}
+
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("failed to save cassette, %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read cassette, %s", err)
+	}
+
+	for _, secret := range []string{"secret-token", "hunter2", "abc123", "tok-456", "qs-key", "form-secret", "form-pass"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("expected %s to be redacted from cassette\n%s", secret, data)
+		}
+	}
+
+	server.Close()
+
+	replayer, err := cassette.NewReplayer(path, &cassette.Options{Matcher: cassette.MatchAll(cassette.MatchMethodURL, cassette.MatchBody)})
+	if err != nil {
+		t.Fatalf("failed to load cassette, %s", err)
+	}
+
+	// Replay in a different order, the body matcher selects the interaction.
+	replayed := send(t, replayer, server.URL, []call{calls[2], calls[1], calls[0], calls[3], calls[4]})
+
+	for i, expected := range []string{recorded[2], recorded[1], recorded[0], recorded[3], recorded[4]} {
+		if expected = strings.Replace(expected, "tok-456", "[REDACTED]", 1); replayed[i] != expected {
+			t.Errorf("\nCall: %d\nExpected: %s\nGot.....: %s", i+1, expected, replayed[i])
+		}
+	}
+
+	if !strings.Contains(recorded[3], `"\xff\xfe\x00\x01"`) || len(replayer.Unused()) != 0 {
+		t.Errorf("unexpected binary response %s or unused interactions %d", recorded[3], len(replayer.Unused()))
+	}
+}
+
+func TestReplayUnmatched(t *testing.T) {
+	server := apiServer(t)
+	path := filepath.Join(t.TempDir(), "api.json")
+
+	recorder := cassette.NewRecorder(path, &cassette.Options{Redact: &httpclient.LogOptions{RedactFields: []string{"method"}}})
+	send(t, recorder, server.URL, []call{{httpclient.Get, "a", nil}, {httpclient.Get, "b", nil}})
+
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("failed to save cassette, %s", err)
+	}
+
+	replayer, err := cassette.NewReplayer(path, nil)
+	if err != nil {
+		t.Fatalf("failed to load cassette, %s", err)
+	}
+
+	results := send(t, replayer, server.URL, []call{{httpclient.Get, "a", nil}, {httpclient.Get, "a", nil}, {httpclient.Delete, "b", nil}})
+
+	tests := []struct {
+		testNum  int
+		result   string
+		expected string
+	}{
+		{1, results[0], `201 application/json "{\"method\":\"[REDACTED]\",\"name\":\"\",\"path\":\"/a\",\"token\":\"[REDACTED]\"}"`},
+		{2, results[1], "no recorded interaction matches request: GET " + server.URL + "/a"},
+		{3, results[2], "no recorded interaction matches request: DELETE " + server.URL + "/b"},
+	}
+
+	for _, test := range tests {
+		if !strings.Contains(test.result, test.expected) {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %s", test.testNum, test.expected, test.result)
+		}
+	}
+
+	if unused := replayer.Unused(); len(unused) != 1 || unused[0].Request.URL != server.URL+"/b" {
+		t.Errorf("expected interaction for /b to be unused, got %v", unused)
+	}
+
+	if _, err := cassette.NewReplayer(filepath.Join(t.TempDir(), "missing.json"), nil); !errors.Is(err, cassette.ErrorCassette) {
+		t.Errorf("expected cassette error, got %v", err)
+	}
+}
+
+func TestRecorderRequest(t *testing.T) {
+	server := apiServer(t)
+	recorder := cassette.NewRecorder(filepath.Join(t.TempDir(), "api.json"), nil)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/items", strings.NewReader(`{"name":"a","password":"hunter2"}`))
+	if err != nil {
+		t.Fatalf("failed to create request, %s", err)
+	}
+
+	req.Header.Set(httpclient.ContentType, httpclient.AppJSON)
+	body := req.Body
+
+	resp, err := recorder.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("request failed, %s", err)
+	}
+
+	resp.Body.Close() // nolint:errcheck,gosec // ok
+
+	if req.Body != body || req.GetBody == nil {
+		t.Errorf("expected the caller's request body to be unchanged")
+	}
+
+	for _, interaction := range recorder.Interactions() {
+		tests := []struct {
+			testNum int
+			header  http.Header
+			body    []byte
+		}{
+			{1, interaction.Request.Header, interaction.Request.Body},
+			{2, interaction.Response.Header, interaction.Response.Body},
+		}
+
+		for _, test := range tests {
+			if length := test.header.Get("Content-Length"); len(length) > 0 && length != fmt.Sprint(len(test.body)) {
+				t.Errorf("\nTest: %d\nExpected: Content-Length %d\nGot.....: %s", test.testNum, len(test.body), length)
+			}
+		}
+
+		if interaction.Response.Header.Get("Content-Length") == "" {
+			t.Errorf("expected response Content-Length to be recorded")
+		}
+	}
+}
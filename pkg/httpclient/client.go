@@ -0,0 +1,445 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/paulcarlton-ww/goutils/pkg/logging"
+)
+
+type (
+	// Client holds configuration shared by the requests created from it, it is safe for concurrent use.
+	Client struct {
+		baseURL     *url.URL
+		header      Header
+		timeout     time.Duration
+		retryPolicy RetryPolicy
+		statusCheck StatusCheck
+		logger      logr.Logger
+		transport   http.RoundTripper
+		jar         http.CookieJar
+		redirect    func(req *http.Request, via []*http.Request) error
+		tlsConfig   *tls.Config
+		auth        Authenticator
+		breaker     *CircuitBreaker
+		logOptions  *LogOptions
+		limiter     *RateLimiter
+		cache       *Cache
+		metrics     Metrics
+		proxy       func(*http.Request) (*url.URL, error)
+		dial        func(ctx context.Context, network, addr string) (net.Conn, error)
+	}
+
+	// ClientOption is a function used to configure a Client.
+	ClientOption func(c *Client) error
+
+	// RequestBuilder is a fluent builder for requests sent using a Client.
+	RequestBuilder struct {
+		client      *Client
+		ctx         context.Context
+		method      string
+		path        string
+		query       url.Values
+		header      Header
+		body        interface{}
+		timeout     time.Duration
+		retryPolicy RetryPolicy
+		statusCheck StatusCheck
+		auth        Authenticator
+	}
+)
+
+// NewClient returns a Client configured by the options, requests use a thirty second timeout, the default
+// BackoffPolicy and accept 2xx status codes unless configured otherwise.
+func NewClient(options ...ClientOption) (*Client, error) {
+	c := &Client{
+		header:      Header{},
+		timeout:     DefaultTimeout,
+		retryPolicy: NewBackoffPolicy(),
+		statusCheck: Is2xx,
+	}
+
+	for _, option := range options {
+		if err := option(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.logger == nil {
+		c.logger = logging.NewLogger("httpClient", &zap.Options{})
+	}
+
+	if c.transport == nil {
+		c.transport = newTransport()
+	}
+
+	if c.proxy != nil || c.dial != nil {
+		transport, err := applyNetworkConfig(c.transport, c.proxy, c.dial)
+		if err != nil {
+			return nil, err
+		}
+
+		c.transport = transport
+	}
+
+	// TLS is applied last as it may wrap the transport's dialer.
+	if c.tlsConfig != nil {
+		transport, err := applyTLSConfig(c.transport, c.tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		c.transport = transport
+	}
+
+	return c, nil
+}
+
+// WithBaseURL sets the URL that request paths are resolved against.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) error {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrorInvalidURL, err)
+		}
+
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+
+		c.baseURL = u
+
+		return nil
+	}
+}
+
+// WithHeader sets a header sent with every request.
+func WithHeader(name, value string) ClientOption {
+	return func(c *Client) error {
+		c.header[name] = value
+
+		return nil
+	}
+}
+
+// WithTimeout sets the default timeout of each attempt.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) error {
+		c.timeout = timeout
+
+		return nil
+	}
+}
+
+// WithRetryPolicy sets the default retry policy, nil disables retries.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) error {
+		if policy == nil {
+			policy = NoRetryPolicy()
+		}
+
+		c.retryPolicy = policy
+
+		return nil
+	}
+}
+
+// WithStatusCheck sets the default function used to decide which response status codes are successful.
+func WithStatusCheck(check StatusCheck) ClientOption {
+	return func(c *Client) error {
+		if check == nil {
+			check = Is2xx
+		}
+
+		c.statusCheck = check
+
+		return nil
+	}
+}
+
+// WithLogger sets the logger.
+func WithLogger(logger logr.Logger) ClientOption {
+	return func(c *Client) error {
+		c.logger = logger
+
+		return nil
+	}
+}
+
+// WithTransport sets the transport, by default each Client has its own transport.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *Client) error {
+		c.transport = transport
+
+		return nil
+	}
+}
+
+// WithCookieJar sets the cookie jar.
+func WithCookieJar(jar http.CookieJar) ClientOption {
+	return func(c *Client) error {
+		c.jar = jar
+
+		return nil
+	}
+}
+
+// WithCheckRedirect sets the redirect policy, see http.Client.CheckRedirect.
+func WithCheckRedirect(redirect func(req *http.Request, via []*http.Request) error) ClientOption {
+	return func(c *Client) error {
+		c.redirect = redirect
+
+		return nil
+	}
+}
+
+// WithAuth sets the Authenticator used to add credentials to requests.
+func WithAuth(auth Authenticator) ClientOption {
+	return func(c *Client) error {
+		c.auth = auth
+
+		return nil
+	}
+}
+
+// WithCircuitBreaker sets the CircuitBreaker used by requests, it may be shared between clients.
+func WithCircuitBreaker(breaker *CircuitBreaker) ClientOption {
+	return func(c *Client) error {
+		c.breaker = breaker
+
+		return nil
+	}
+}
+
+// WithLogOptions sets the options controlling the logging of requests and responses.
+func WithLogOptions(opts *LogOptions) ClientOption {
+	return func(c *Client) error {
+		c.logOptions = opts
+
+		return nil
+	}
+}
+
+// WithRateLimiter sets the RateLimiter used by requests, it may be shared between clients.
+func WithRateLimiter(limiter *RateLimiter) ClientOption {
+	return func(c *Client) error {
+		c.limiter = limiter
+
+		return nil
+	}
+}
+
+// WithCache sets the Cache used by requests, it may be shared between clients.
+func WithCache(cache *Cache) ClientOption {
+	return func(c *Client) error {
+		c.cache = cache
+
+		return nil
+	}
+}
+
+// WithMetrics sets the Metrics used to record requests, it may be shared between clients.
+func WithMetrics(metrics Metrics) ClientOption {
+	return func(c *Client) error {
+		c.metrics = metrics
+
+		return nil
+	}
+}
+
+// Transport returns the client's transport.
+func (c *Client) Transport() http.RoundTripper {
+	return c.transport
+}
+
+// NewRequest returns a builder for a request with the given method and path, the path is resolved against the
+// client's base URL and may be an absolute URL.
+func (c *Client) NewRequest(ctx context.Context, method, path string) *RequestBuilder {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return &RequestBuilder{
+		client:      c,
+		ctx:         ctx,
+		method:      method,
+		path:        path,
+		query:       url.Values{},
+		header:      Header{},
+		timeout:     c.timeout,
+		retryPolicy: c.retryPolicy,
+		statusCheck: c.statusCheck,
+		auth:        c.auth,
+	}
+}
+
+// Get returns a builder for a GET request.
+func (c *Client) Get(ctx context.Context, path string) *RequestBuilder {
+	return c.NewRequest(ctx, Get, path)
+}
+
+// Post returns a builder for a POST request.
+func (c *Client) Post(ctx context.Context, path string, body interface{}) *RequestBuilder {
+	return c.NewRequest(ctx, Post, path).Body(body)
+}
+
+// Put returns a builder for a PUT request.
+func (c *Client) Put(ctx context.Context, path string, body interface{}) *RequestBuilder {
+	return c.NewRequest(ctx, Put, path).Body(body)
+}
+
+// Patch returns a builder for a PATCH request.
+func (c *Client) Patch(ctx context.Context, path string, body interface{}) *RequestBuilder {
+	return c.NewRequest(ctx, Patch, path).Body(body)
+}
+
+// Delete returns a builder for a DELETE request.
+func (c *Client) Delete(ctx context.Context, path string) *RequestBuilder {
+	return c.NewRequest(ctx, Delete, path)
+}
+
+// Query adds a query parameter.
+func (b *RequestBuilder) Query(name, value string) *RequestBuilder {
+	b.query.Add(name, value)
+
+	return b
+}
+
+// Header sets a header, overriding the client's header of the same name.
+func (b *RequestBuilder) Header(name, value string) *RequestBuilder {
+	b.header[name] = value
+
+	return b
+}
+
+// Body sets the request body, see NewReqResp for how bodies are encoded.
+func (b *RequestBuilder) Body(body interface{}) *RequestBuilder {
+	b.body = body
+
+	return b
+}
+
+// Timeout sets the timeout of each attempt.
+func (b *RequestBuilder) Timeout(timeout time.Duration) *RequestBuilder {
+	b.timeout = timeout
+
+	return b
+}
+
+// RetryPolicy sets the retry policy, nil disables retries.
+func (b *RequestBuilder) RetryPolicy(policy RetryPolicy) *RequestBuilder {
+	if policy == nil {
+		policy = NoRetryPolicy()
+	}
+
+	b.retryPolicy = policy
+
+	return b
+}
+
+// StatusCheck sets the function used to decide which response status codes are successful.
+func (b *RequestBuilder) StatusCheck(check StatusCheck) *RequestBuilder {
+	if check == nil {
+		check = Is2xx
+	}
+
+	b.statusCheck = check
+
+	return b
+}
+
+// Auth sets the Authenticator, overriding the client's, nil sends no credentials.
+func (b *RequestBuilder) Auth(auth Authenticator) *RequestBuilder {
+	b.auth = auth
+
+	return b
+}
+
+// URL returns the request URL, the path resolved against the client's base URL with the query parameters added.
+// Paths are relative to the base URL's path even if they start with a '/', so "/items" with a base URL of
+// "https://host/api" gives "https://host/api/items".
+func (b *RequestBuilder) URL() (*url.URL, error) {
+	u, err := url.Parse(b.path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidURL, err)
+	}
+
+	if b.client.baseURL != nil && !u.IsAbs() {
+		u = b.client.baseURL.ResolveReference(&url.URL{Path: strings.TrimPrefix(u.Path, "/"), RawQuery: u.RawQuery})
+	}
+
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("%w: %s is not absolute and the client has no base URL", ErrorInvalidURL, u)
+	}
+
+	if len(b.query) > 0 {
+		query := u.Query()
+
+		for name, values := range b.query {
+			for _, value := range values {
+				query.Add(name, value)
+			}
+		}
+
+		u.RawQuery = query.Encode()
+	}
+
+	return u, nil
+}
+
+// Build returns the request.
+func (b *RequestBuilder) Build() (ReqResp, error) {
+	u, err := b.URL()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(Header, len(b.client.header)+len(b.header))
+
+	for name, value := range b.client.header {
+		header[name] = value
+	}
+
+	for name, value := range b.header {
+		header[name] = value
+	}
+
+	method := b.method
+	timeout := b.timeout
+	client := &http.Client{Transport: b.client.transport, Jar: b.client.jar, CheckRedirect: b.client.redirect}
+
+	r, err := NewReqResp(b.ctx, u, &method, b.body, header, &timeout, b.client.logger, client, b.client.transport)
+	if err != nil {
+		return nil, err
+	}
+
+	r.SetRetryPolicy(b.retryPolicy)
+	r.SetStatusCheck(b.statusCheck)
+	r.SetAuth(b.auth)
+	r.SetCircuitBreaker(b.client.breaker)
+	r.SetLogOptions(b.client.logOptions)
+	r.SetRateLimiter(b.client.limiter)
+	r.SetCache(b.client.cache)
+	r.SetMetrics(b.client.metrics)
+
+	return r, nil
+}
+
+// Do builds and sends the request.
+func (b *RequestBuilder) Do() (ReqResp, error) {
+	r, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, r.HTTPreq()
+}
@@ -0,0 +1,108 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+)
+
+// requestServer responds with the request method, URI, selected headers and body.
+func requestServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body, %s", err)
+		}
+
+		if r.URL.Path == "/api/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		fmt.Fprintf(w, "%s %s|%s|%s|%s", r.Method, r.URL.RequestURI(), r.Header.Get("X-Client"), r.Header.Get("X-Request"), body)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestClient(t *testing.T) {
+	server := requestServer(t)
+
+	client, err := httpclient.NewClient(
+		httpclient.WithBaseURL(server.URL+"/api"),
+		httpclient.WithHeader("X-Client", "default"),
+		httpclient.WithHeader("X-Request", "default"),
+		httpclient.WithRetryPolicy(nil),
+		httpclient.WithTimeout(time.Second),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	ctx := context.Background()
+
+	tests := []struct {
+		testNum  int
+		builder  *httpclient.RequestBuilder
+		expected string
+	}{
+		{1, client.Get(ctx, "items"), "GET /api/items|default|default|"},
+		{2, client.Get(ctx, "/items").Query("page", "2").Query("page", "3"), "GET /api/items?page=2&page=3|default|default|"},
+		{3, client.Get(ctx, "items?sort=name").Query("page", "2"), "GET /api/items?page=2&sort=name|default|default|"},
+		{4, client.Post(ctx, "items", map[string]string{"a": "b"}).Header("X-Request", "override"), `POST /api/items|default|override|{"a":"b"}`},
+		{5, client.Put(ctx, "items/1", []byte("raw")), "PUT /api/items/1|default|default|raw"},
+		{6, client.Patch(ctx, "items/1", httpclient.MergePatch{Patch: map[string]int{"a": 1}}), `PATCH /api/items/1|default|default|{"a":1}`},
+		{7, client.Delete(ctx, "items/1"), "DELETE /api/items/1|default|default|"},
+		{8, client.Get(ctx, server.URL+"/other"), "GET /other|default|default|"},
+	}
+
+	for _, test := range tests {
+		r, err := test.builder.Do()
+		if err != nil {
+			t.Errorf("\nTest: %d\nUnexpected error: %s", test.testNum, err)
+
+			continue
+		}
+
+		if r.RespBody() != test.expected {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %s", test.testNum, test.expected, r.RespBody())
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	server := requestServer(t)
+
+	if _, err := httpclient.NewClient(httpclient.WithBaseURL("://bad")); !errors.Is(err, httpclient.ErrorInvalidURL) {
+		t.Errorf("expected invalid url error, got %v", err)
+	}
+
+	client, err := httpclient.NewClient(httpclient.WithRetryPolicy(nil))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	if _, err := client.Get(context.Background(), "items").Build(); !errors.Is(err, httpclient.ErrorInvalidURL) {
+		t.Errorf("expected invalid url error for relative path without base url, got %v", err)
+	}
+
+	_, err = client.Get(context.Background(), server.URL+"/api/slow").Timeout(10 * time.Millisecond).Do()
+	if !httpclient.IsRetryableError(err) {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+
+	r, err := client.Get(context.Background(), server.URL+"/api/slow").StatusCheck(httpclient.SuccessStatus(http.StatusCreated)).Do()
+
+	var statusErr *httpclient.StatusError
+	if !errors.As(err, &statusErr) || r.ResponseCode() != http.StatusOK {
+		t.Errorf("expected status error, got %v", err)
+	}
+}
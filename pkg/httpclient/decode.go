@@ -0,0 +1,133 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrorDecodingRespBody = errors.New("failed to decode response body")
+
+func decodingResponseBodyError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrorDecodingRespBody, msg)
+}
+
+// APIError is returned by DoJSON and DecodeJSONArray when a request fails with an error response.
+// Detail holds the response body decoded as E, nil if the body could not be decoded.
+type APIError[E any] struct {
+	StatusCode int
+	Body       string
+	Detail     *E
+	Err        error
+}
+
+// Error implements the error interface.
+func (e *APIError[E]) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error returned by the request.
+func (e *APIError[E]) Unwrap() error {
+	return e.Err
+}
+
+// DecodeJSON decodes the response body of a completed request as T, an empty body gives the zero value of T.
+func DecodeJSON[T any](r ReqResp) (T, error) {
+	var result T
+
+	body := r.RespBody()
+	if len(strings.TrimSpace(body)) == 0 {
+		return result, nil
+	}
+
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		return result, decodingResponseBodyError(err.Error())
+	}
+
+	return result, nil
+}
+
+// DoJSON sends the request and decodes the response body as T.
+// If the server returns an error response the error is an *APIError[E] holding the response body decoded as E.
+func DoJSON[T, E any](req ReqResp) (T, error) {
+	var result T
+
+	r, err := asReqResp(req)
+	if err != nil {
+		return result, err
+	}
+
+	if err := r.HTTPreq(); err != nil {
+		return result, newAPIError[E](r, err)
+	}
+
+	return DecodeJSON[T](r)
+}
+
+// DecodeJSONArray sends the request and decodes the response body, a JSON array, one element at a time, calling fn
+// with each element. The body is not buffered so arbitrarily large arrays can be processed. Decoding stops if fn
+// returns an error. If the server returns an error response the error is an *APIError[E].
+func DecodeJSONArray[T, E any](req ReqResp, fn func(T) error) error {
+	r, err := asReqResp(req)
+	if err != nil {
+		return err
+	}
+
+	if err := r.send(false); err != nil {
+		return newAPIError[E](r, err)
+	}
+
+	defer r.CloseBody()
+
+	decoder := json.NewDecoder(r.response().Body)
+
+	if err := expectDelim(decoder, '['); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		var item T
+
+		if err := decoder.Decode(&item); err != nil {
+			return decodingResponseBodyError(err.Error())
+		}
+
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(decoder, ']')
+}
+
+// expectDelim reads the next token and checks it is the expected delimiter.
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return decodingResponseBodyError(err.Error())
+	}
+
+	if token != delim {
+		return decodingResponseBodyError(fmt.Sprintf("expected %s, got %v", delim, token))
+	}
+
+	return nil
+}
+
+// newAPIError returns an *APIError[E] if the request received a response, or the error if not.
+func newAPIError[E any](r *reqResp, err error) error {
+	resp := r.response()
+	if resp == nil {
+		return err
+	}
+
+	apiErr := &APIError[E]{StatusCode: resp.StatusCode, Body: r.RespBody(), Err: err}
+
+	detail := new(E)
+	if json.Unmarshal([]byte(apiErr.Body), detail) == nil {
+		apiErr.Detail = detail
+	}
+
+	return apiErr
+}
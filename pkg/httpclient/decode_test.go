@@ -0,0 +1,113 @@
+package httpclient_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+)
+
+type item struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type apiProblem struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// jsonServer returns a mock server responding to each path with a fixed JSON document, status or text.
+func jsonServer(t *testing.T) *mockserver.Server {
+	server := mockserver.New(t)
+	server.Expect(httpclient.Get, "/item").Respond(mockserver.JSON(http.StatusOK, `{"name":"a","count":1}`))
+	server.Expect(httpclient.Get, "/items").Respond(
+		mockserver.JSON(http.StatusOK, `[{"name":"a","count":1},{"name":"b","count":2},{"name":"c","count":3}]`))
+	server.Expect(httpclient.Get, "/empty").Respond(mockserver.Status(http.StatusOK))
+	server.Expect(httpclient.Get, "/object").Respond(mockserver.JSON(http.StatusOK, `{"items":[]}`))
+	server.Expect(httpclient.Get, "/problem").Respond(
+		mockserver.JSON(http.StatusNotFound, `{"code":"NotFound","message":"no such item"}`))
+	server.Expect(httpclient.Get, "/text").Respond(mockserver.Text(http.StatusInternalServerError, "not json"))
+
+	return server
+}
+
+func TestDoJSON(t *testing.T) {
+	server := jsonServer(t)
+
+	tests := []struct {
+		testNum  int
+		path     string
+		expected item
+		status   int
+		detail   *apiProblem
+	}{
+		{1, "/item", item{Name: "a", Count: 1}, 0, nil},
+		{2, "/empty", item{}, 0, nil},
+		{3, "/problem", item{}, http.StatusNotFound, &apiProblem{Code: "NotFound", Message: "no such item"}},
+		{4, "/text", item{}, http.StatusInternalServerError, nil},
+	}
+
+	for _, test := range tests {
+		r := newGet(t, server.URL+test.path)
+		r.SetRetryPolicy(nil)
+
+		result, err := httpclient.DoJSON[item, apiProblem](r)
+
+		var apiErr *httpclient.APIError[apiProblem]
+
+		switch {
+		case test.status == 0 && err != nil:
+			t.Errorf("\nTest: %d\nUnexpected error: %s", test.testNum, err)
+		case test.status != 0 && !errors.As(err, &apiErr):
+			t.Errorf("\nTest: %d\nExpected: APIError\nGot.....: %v", test.testNum, err)
+		case test.status != 0 && (apiErr.StatusCode != test.status || fmt.Sprint(apiErr.Detail) != fmt.Sprint(test.detail)):
+			t.Errorf("\nTest: %d\nExpected: %d, %+v\nGot.....: %d, %+v", test.testNum, test.status, test.detail, apiErr.StatusCode, apiErr.Detail)
+		case test.status != 0 && !errors.Is(err, httpclient.ErrorRequestFailed):
+			t.Errorf("\nTest: %d\nExpected: ErrorRequestFailed\nGot.....: %s", test.testNum, err)
+		case result != test.expected:
+			t.Errorf("\nTest: %d\nExpected: %+v\nGot.....: %+v", test.testNum, test.expected, result)
+		}
+	}
+}
+
+func TestDecodeJSONArray(t *testing.T) {
+	server := jsonServer(t)
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		testNum  int
+		path     string
+		stopAt   int
+		expected []item
+		err      error
+	}{
+		{1, "/items", 0, []item{{"a", 1}, {"b", 2}, {"c", 3}}, nil},
+		{2, "/items", 2, []item{{"a", 1}, {"b", 2}}, errStop},
+		{3, "/object", 0, nil, httpclient.ErrorDecodingRespBody},
+		{4, "/problem", 0, nil, httpclient.ErrorRequestFailed},
+	}
+
+	for _, test := range tests {
+		r := newGet(t, server.URL+test.path)
+		r.SetRetryPolicy(nil)
+
+		var items []item
+
+		err := httpclient.DecodeJSONArray[item, apiProblem](r, func(i item) error {
+			items = append(items, i)
+			if len(items) == test.stopAt {
+				return errStop
+			}
+
+			return nil
+		})
+
+		if !errors.Is(err, test.err) || fmt.Sprint(items) != fmt.Sprint(test.expected) {
+			t.Errorf("\nTest: %d\nExpected: %v, %v\nGot.....: %v, %v", test.testNum, test.expected, test.err, items, err)
+		}
+	}
+}
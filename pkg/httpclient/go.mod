@@ -1,9 +1,31 @@
 module github.com/paulcarlton-ww/goutils/pkg/httpclient
 
-go 1.16
+go 1.18
 
 require (
 	github.com/go-logr/logr v0.4.0
-	github.com/paulcarlton-ww/goutils/pkg/logging v0.0.4
+	github.com/paulcarlton-ww/goutils/pkg/logging v0.0.5
+	golang.org/x/net v0.0.0-20210428140749-89ef3d95e781
 	sigs.k8s.io/controller-runtime v0.9.2
 )
+
+require (
+	github.com/go-logr/zapr v0.4.0 // indirect
+	github.com/gogo/protobuf v1.3.2 // indirect
+	github.com/google/go-cmp v0.5.5 // indirect
+	github.com/google/gofuzz v1.1.0 // indirect
+	github.com/json-iterator/go v1.1.11 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.1 // indirect
+	go.uber.org/atomic v1.7.0 // indirect
+	go.uber.org/multierr v1.6.0 // indirect
+	go.uber.org/zap v1.17.0 // indirect
+	golang.org/x/text v0.3.6 // indirect
+	gopkg.in/inf.v0 v0.9.1 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+	k8s.io/apimachinery v0.21.2 // indirect
+	k8s.io/klog/v2 v2.8.0 // indirect
+	sigs.k8s.io/structured-merge-diff/v4 v4.1.0 // indirect
+)
+
+replace github.com/paulcarlton-ww/goutils/pkg/logging => ../logging
@@ -0,0 +1,445 @@
+// Package graphql provides a GraphQL client built on httpclient, executing queries and mutations with typed variables
+// and results, parsing GraphQL errors and paginating Relay style connections.
+package graphql
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+)
+
+const (
+	defaultCursorVariable = "after"
+	idempotencyKeySize    = 16
+)
+
+var ErrorGraphQL = errors.New("graphql request failed")
+
+func graphQLError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrorGraphQL, msg)
+}
+
+type (
+	// Client sends GraphQL requests to an endpoint using an httpclient.Client, it is safe for concurrent use.
+	Client struct {
+		client   *httpclient.Client
+		endpoint string
+	}
+
+	// Request is a GraphQL request.
+	Request struct {
+		Query         string      `json:"query"`
+		Variables     interface{} `json:"variables,omitempty"` // Encoded as JSON, e.g. a struct or map.
+		OperationName string      `json:"operationName,omitempty"`
+		// RetryPolicy used to send the request, if not set queries use the client's policy and mutations are not
+		// retried. Use httpclient.NoRetryPolicy() to disable retries.
+		RetryPolicy httpclient.RetryPolicy `json:"-"`
+	}
+
+	// Response is a GraphQL response with data decoded as T.
+	Response[T any] struct {
+		Data       T                      `json:"data"`
+		Errors     Errors                 `json:"errors,omitempty"`
+		Extensions map[string]interface{} `json:"extensions,omitempty"`
+	}
+
+	// Error is an error in a GraphQL response.
+	Error struct {
+		Message    string                 `json:"message"`
+		Locations  []Location             `json:"locations,omitempty"`
+		Path       []interface{}          `json:"path,omitempty"` // Field names and list indexes.
+		Extensions map[string]interface{} `json:"extensions,omitempty"`
+	}
+
+	// Location is a position in a GraphQL query.
+	Location struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	}
+
+	// Errors is the list of errors in a GraphQL response, it wraps ErrorGraphQL.
+	Errors []*Error
+
+	// PageInfo is the page information of a Relay style connection.
+	PageInfo struct {
+		HasNextPage bool   `json:"hasNextPage"`
+		EndCursor   string `json:"endCursor"`
+	}
+
+	// Connection is a Relay style connection, for use in result types. Either nodes or edges may be queried.
+	Connection[N any] struct {
+		Nodes    []N       `json:"nodes,omitempty"`
+		Edges    []Edge[N] `json:"edges,omitempty"`
+		PageInfo PageInfo  `json:"pageInfo"`
+	}
+
+	// Edge is an edge of a Relay style connection.
+	Edge[N any] struct {
+		Cursor string `json:"cursor"`
+		Node   N      `json:"node"`
+	}
+
+	// PageOptions holds the settings of a Paginator.
+	PageOptions struct {
+		CursorVariable string // Variable the cursor of the next page is passed in, defaults to "after".
+		MaxPages       int    // Maximum number of pages fetched, defaults to httpclient.DefaultMaxPages.
+	}
+
+	// Paginator iterates over the items of a Relay style connection, fetching pages as required.
+	//
+	//	for p.Next() {
+	//		item := p.Item()
+	//	}
+	//	if err := p.Err(); err != nil {
+	//	}
+	Paginator[T, N any] struct {
+		ctx        context.Context
+		client     *Client
+		query      string
+		variables  map[string]interface{}
+		connection func(data T) ([]N, PageInfo)
+		opts       PageOptions
+		cursor     *string
+		done       bool
+		items      []N
+		index      int
+		item       N
+		pages      int
+		err        error
+	}
+)
+
+// NewClient returns a Client sending requests to the endpoint, which is resolved against the client's base URL.
+func NewClient(client *httpclient.Client, endpoint string) *Client {
+	return &Client{client: client, endpoint: endpoint}
+}
+
+// Execute sends the request, returning the response with its data decoded as T. If the response contains errors
+// the response is returned with its Errors as the error, so partial data can be used. GraphQL errors in an error
+// response are also returned as Errors, other failures return the error from httpclient.
+// A mutation is sent once unless the request's RetryPolicy is set, as it may have been applied even if the request
+// failed. Other operations are sent with an Idempotency-Key header so the retry policy retries them although they are
+// sent using POST.
+func Execute[T any](ctx context.Context, c *Client, req *Request) (*Response[T], error) {
+	b := c.client.Post(ctx, c.endpoint, req).Header("Accept", "application/json")
+
+	mutation := operationType(req.Query, req.OperationName) == "mutation"
+	if !mutation {
+		b.Header("Idempotency-Key", idempotencyKey())
+	}
+
+	switch {
+	case req.RetryPolicy != nil:
+		b.RetryPolicy(req.RetryPolicy)
+	case mutation:
+		b.RetryPolicy(nil)
+	}
+
+	r, err := b.Do()
+	if err != nil {
+		var statusErr *httpclient.StatusError
+		if errors.As(err, &statusErr) {
+			resp := &Response[T]{}
+			if json.Unmarshal([]byte(statusErr.Body), resp) == nil && len(resp.Errors) > 0 {
+				return resp, resp.Errors
+			}
+		}
+
+		return nil, err
+	}
+
+	resp, err := httpclient.DecodeJSON[*Response[T]](r)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp == nil {
+		return nil, graphQLError("empty response")
+	}
+
+	if len(resp.Errors) > 0 {
+		return resp, resp.Errors
+	}
+
+	return resp, nil
+}
+
+// idempotencyKey returns a random key marking a request as safe to repeat.
+func idempotencyKey() string {
+	key := make([]byte, idempotencyKeySize)
+	rand.Read(key) // nolint:errcheck,gosec // ok
+
+	return hex.EncodeToString(key)
+}
+
+// Do executes a query or mutation with the variables, returning the data decoded as T. If the response contains
+// errors they are returned as Errors along with any partial data.
+func Do[T any](ctx context.Context, c *Client, query string, variables interface{}) (T, error) {
+	resp, err := Execute[T](ctx, c, &Request{Query: query, Variables: variables})
+	if resp == nil {
+		var data T
+
+		return data, err
+	}
+
+	return resp.Data, err
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+
+	path := make([]string, len(e.Path))
+	for i, element := range e.Path {
+		path[i] = fmt.Sprint(element)
+	}
+
+	return fmt.Sprintf("%s at %s", e.Message, strings.Join(path, "."))
+}
+
+// Code returns the error code in the extensions, an empty string if there is none.
+func (e *Error) Code() string {
+	code, _ := e.Extensions["code"].(string)
+
+	return code
+}
+
+// Error implements the error interface.
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return graphQLError(strings.Join(messages, "; ")).Error()
+}
+
+// Unwrap returns ErrorGraphQL.
+func (e Errors) Unwrap() error {
+	return ErrorGraphQL
+}
+
+// Items returns the nodes of the connection, taken from the edges if nodes were not queried.
+func (c Connection[N]) Items() []N {
+	if c.Nodes != nil {
+		return c.Nodes
+	}
+
+	items := make([]N, len(c.Edges))
+	for i, edge := range c.Edges {
+		items[i] = edge.Node
+	}
+
+	return items
+}
+
+// Paginate returns a Paginator executing the query for each page, passing the cursor of the previous page in the
+// cursor variable. The connection function returns the items and page information of a page, e.g.
+//
+//	func(data Result) ([]Repo, graphql.PageInfo) { return data.Viewer.Repos.Items(), data.Viewer.Repos.PageInfo }
+//
+// Fetching stops if the context is cancelled, a nil context is treated as context.Background().
+func Paginate[T, N any](ctx context.Context, c *Client, query string, variables map[string]interface{},
+	connection func(data T) ([]N, PageInfo), opts *PageOptions) *Paginator[T, N] {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	p := &Paginator[T, N]{ctx: ctx, client: c, query: query, variables: variables, connection: connection}
+
+	if opts != nil {
+		p.opts = *opts
+	}
+
+	if len(p.opts.CursorVariable) == 0 {
+		p.opts.CursorVariable = defaultCursorVariable
+	}
+
+	if p.opts.MaxPages <= 0 {
+		p.opts.MaxPages = httpclient.DefaultMaxPages
+	}
+
+	return p
+}
+
+// Next advances to the next item, fetching the next page if required. It returns false when there are no more items
+// or an error occurs.
+func (p *Paginator[T, N]) Next() bool {
+	for p.index >= len(p.items) {
+		if p.err != nil || p.done {
+			return false
+		}
+
+		p.err = p.fetch()
+	}
+
+	p.item = p.items[p.index]
+	p.index++
+
+	return true
+}
+
+// Item returns the current item.
+func (p *Paginator[T, N]) Item() N {
+	return p.item
+}
+
+// Err returns the error that stopped the iteration, nil if all the items were returned.
+func (p *Paginator[T, N]) Err() error {
+	return p.err
+}
+
+// Pages returns the number of pages fetched.
+func (p *Paginator[T, N]) Pages() int {
+	return p.pages
+}
+
+// All returns the remaining items.
+func (p *Paginator[T, N]) All() ([]N, error) {
+	items := []N{}
+
+	for p.Next() {
+		items = append(items, p.Item())
+	}
+
+	return items, p.Err()
+}
+
+// fetch fetches the next page.
+func (p *Paginator[T, N]) fetch() error {
+	if err := p.ctx.Err(); err != nil {
+		return err
+	}
+
+	if p.pages >= p.opts.MaxPages {
+		return fmt.Errorf("%w: %d", httpclient.ErrorMaxPages, p.pages)
+	}
+
+	variables := make(map[string]interface{}, len(p.variables)+1)
+	for name, value := range p.variables {
+		variables[name] = value
+	}
+
+	if p.cursor != nil {
+		variables[p.opts.CursorVariable] = *p.cursor
+	}
+
+	data, err := Do[T](p.ctx, p.client, p.query, variables)
+	if err != nil {
+		return err
+	}
+
+	p.pages++
+
+	items, info := p.connection(data)
+	p.items, p.index = items, 0
+
+	if !info.HasNextPage || len(info.EndCursor) == 0 {
+		p.done = true
+
+		return nil
+	}
+
+	p.cursor = &info.EndCursor
+
+	return nil
+}
+
+// operationType returns the type of the named operation in the document, or of its first operation if name is empty.
+// The type is "query", "mutation" or "subscription", empty if the operation is not found.
+func operationType(document, name string) string {
+	var keyword, found string
+
+	depth := 0
+	header := false // Set after an operation or fragment keyword until its selection set starts.
+
+	for i := 0; i < len(document); i++ {
+		c := document[i]
+
+		switch {
+		case c == '#':
+			for i < len(document) && document[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			i = skipString(document, i)
+		case c == '{' || c == '(' || c == '[':
+			if depth == 0 && c == '{' && !header && len(name) == 0 && len(found) == 0 {
+				return "query" // Query shorthand.
+			}
+
+			if c == '{' {
+				keyword, header = "", false
+			}
+
+			depth++
+		case c == '}' || c == ')' || c == ']':
+			depth--
+		case depth == 0 && isNameStart(c):
+			start := i
+			for i+1 < len(document) && isNameChar(document[i+1]) {
+				i++
+			}
+
+			token := document[start : i+1]
+
+			switch {
+			case len(keyword) > 0:
+				if keyword != "fragment" && token == name {
+					return keyword
+				}
+
+				keyword = ""
+			case !header && (token == "query" || token == "mutation" || token == "subscription" || token == "fragment"):
+				keyword, header = token, true
+
+				if token != "fragment" && len(name) == 0 && len(found) == 0 {
+					found = token
+				}
+			}
+		}
+	}
+
+	if len(name) > 0 {
+		return ""
+	}
+
+	return found
+}
+
+// skipString returns the index of the closing quote of the string or block string starting at index i.
+func skipString(document string, i int) int {
+	if strings.HasPrefix(document[i:], `"""`) {
+		if end := strings.Index(document[i+3:], `"""`); end >= 0 {
+			return i + 3 + end + 2
+		}
+
+		return len(document)
+	}
+
+	for i++; i < len(document) && document[i] != '"'; i++ {
+		if document[i] == '\\' {
+			i++
+		}
+	}
+
+	return i
+}
+
+// isNameStart returns true if c can start a GraphQL name.
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isNameChar returns true if c can be part of a GraphQL name.
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
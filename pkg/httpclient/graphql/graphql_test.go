@@ -0,0 +1,275 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/graphql"
+)
+
+type (
+	greeting struct {
+		Hello string `json:"hello"`
+	}
+
+	greetingVars struct {
+		Name string `json:"name"`
+	}
+
+	repo struct {
+		Name string `json:"name"`
+	}
+
+	repos struct {
+		Viewer struct {
+			Repositories graphql.Connection[repo] `json:"repositories"`
+		} `json:"viewer"`
+	}
+)
+
+// graphQLServer is a fake GraphQL endpoint choosing its response by the operation in the query.
+func graphQLServer(t *testing.T) *httptest.Server {
+	names := []string{"a", "b", "c", "d", "e"}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+
+		if r.URL.Path != "/graphql" || r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set(httpclient.ContentType, "application/json")
+
+		switch {
+		case strings.Contains(req.Query, "hello"):
+			fmt.Fprintf(w, `{"data":{"hello":"Hello, %s"}}`, req.Variables["name"])
+		case strings.Contains(req.Query, "partial"):
+			fmt.Fprint(w, `{"data":{"hello":"partial"},"errors":[{"message":"not allowed","path":["secret",0,"value"],`+
+				`"locations":[{"line":2,"column":3}],"extensions":{"code":"FORBIDDEN"}},{"message":"rate limited"}]}`)
+		case strings.Contains(req.Query, "invalid"):
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors":[{"message":"syntax error","extensions":{"code":"GRAPHQL_PARSE_FAILED"}}]}`)
+		case strings.Contains(req.Query, "broken"):
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "internal error")
+		case strings.Contains(req.Query, "repositories"):
+			start := 0
+			if after, ok := req.Variables["cursor"].(string); ok {
+				start, _ = strconv.Atoi(after)
+			}
+
+			end := start + 2
+			if end > len(names) {
+				end = len(names)
+			}
+
+			nodes := []string{}
+			for _, name := range names[start:end] {
+				nodes = append(nodes, fmt.Sprintf(`{"node":{"name":%q}}`, name))
+			}
+
+			fmt.Fprintf(w, `{"data":{"viewer":{"repositories":{"edges":[%s],"pageInfo":{"hasNextPage":%t,"endCursor":"%d"}}}}}`,
+				strings.Join(nodes, ","), end < len(names), end)
+		default:
+			fmt.Fprint(w, `null`)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newClient(t *testing.T) *graphql.Client {
+	server := graphQLServer(t)
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURL(server.URL), httpclient.WithRetryPolicy(nil))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	return graphql.NewClient(client, "graphql")
+}
+
+func TestDo(t *testing.T) {
+	client := newClient(t)
+
+	tests := []struct {
+		testNum   int
+		query     string
+		variables interface{}
+		expected  string
+		err       error
+		message   string
+	}{
+		{1, "query($name: String!) { hello(name: $name) }", greetingVars{Name: "world"}, "Hello, world", nil, ""},
+		{2, "query($name: String!) { hello(name: $name) }", map[string]string{"name": "map"}, "Hello, map", nil, ""},
+		{3, "query { partial }", nil, "partial", graphql.ErrorGraphQL,
+			"graphql request failed: not allowed at secret.0.value; rate limited"},
+		{4, "query { invalid", nil, "", graphql.ErrorGraphQL, "graphql request failed: syntax error"},
+		{5, "query { broken }", nil, "", httpclient.ErrorRequestFailed, ""},
+		{6, "query { empty }", nil, "", graphql.ErrorGraphQL, "graphql request failed: empty response"},
+	}
+
+	for _, test := range tests {
+		data, err := graphql.Do[greeting](context.Background(), client, test.query, test.variables)
+		if data.Hello != test.expected || !errors.Is(err, test.err) || (err == nil) != (test.err == nil) ||
+			(len(test.message) > 0 && err.Error() != test.message) {
+			t.Errorf("\nTest: %d\nExpected: %q, %v %s\nGot.....: %q, %v", test.testNum, test.expected, test.err, test.message, data.Hello, err)
+		}
+	}
+}
+
+func TestExecuteRetry(t *testing.T) {
+	policy := httpclient.NewBackoffPolicy()
+	policy.InitialInterval = time.Millisecond
+
+	retryAll := *policy
+	retryAll.RetryNonIdempotent = true
+
+	tests := []struct {
+		testNum   int
+		query     string
+		operation string
+		policy    httpclient.RetryPolicy
+		calls     int32
+		err       error
+	}{
+		{1, "mutation { hello }", "", nil, 1, httpclient.ErrorRequestFailed},
+		{2, "query { hello }", "", nil, 2, nil},
+		{3, "{ hello }", "", nil, 2, nil},
+		{4, `mutation($a: String = "{") { hello }`, "", nil, 1, httpclient.ErrorRequestFailed},
+		{5, "query Q { hello } mutation M { hello }", "M", nil, 1, httpclient.ErrorRequestFailed},
+		{6, "query Q { hello } mutation M { hello }", "Q", nil, 2, nil},
+		{7, "# mutation\nquery { hello }", "", nil, 2, nil},
+		{8, "fragment F on Mutation { hello } mutation { ...F }", "", nil, 1, httpclient.ErrorRequestFailed},
+		{9, "mutation { hello }", "", &retryAll, 2, nil},
+		{10, "query { hello }", "", httpclient.NoRetryPolicy(), 1, httpclient.ErrorRequestFailed},
+		{11, "mutation { hello }", "", policy, 1, httpclient.ErrorRequestFailed},
+		{12, "query { hello }", "", policy, 2, nil},
+	}
+
+	for _, test := range tests {
+		var calls int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+
+			fmt.Fprint(w, `{"data":{"hello":"ok"}}`)
+		}))
+
+		client, err := httpclient.NewClient(httpclient.WithBaseURL(server.URL), httpclient.WithRetryPolicy(policy))
+		if err != nil {
+			t.Fatalf("failed to create client, %s", err)
+		}
+
+		_, err = graphql.Execute[greeting](context.Background(), graphql.NewClient(client, "graphql"),
+			&graphql.Request{Query: test.query, OperationName: test.operation, RetryPolicy: test.policy})
+		if atomic.LoadInt32(&calls) != test.calls || !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Errorf("\nTest: %d\nExpected: %d calls, %v\nGot.....: %d calls, %v", test.testNum, test.calls, test.err, calls, err)
+		}
+
+		server.Close()
+	}
+}
+
+func TestErrors(t *testing.T) {
+	client := newClient(t)
+
+	resp, err := graphql.Execute[greeting](context.Background(), client, &graphql.Request{Query: "query { partial }"})
+
+	var gqlErrors graphql.Errors
+	if !errors.As(err, &gqlErrors) || len(gqlErrors) != 2 || resp.Data.Hello != "partial" {
+		t.Fatalf("expected two errors with partial data, got %v, %v", resp, err)
+	}
+
+	first := gqlErrors[0]
+	if first.Code() != "FORBIDDEN" || fmt.Sprint(first.Locations) != "[{2 3}]" || fmt.Sprint(first.Path) != "[secret 0 value]" {
+		t.Errorf("unexpected error details, code %q, locations %v, path %v", first.Code(), first.Locations, first.Path)
+	}
+
+	if gqlErrors[1].Code() != "" || gqlErrors[1].Error() != "rate limited" {
+		t.Errorf("unexpected error, code %q, %s", gqlErrors[1].Code(), gqlErrors[1])
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	client := newClient(t)
+	query := "query($cursor: String) { viewer { repositories(first: 2, after: $cursor) { edges { node { name } } } } }"
+
+	connection := func(data repos) ([]repo, graphql.PageInfo) {
+		return data.Viewer.Repositories.Items(), data.Viewer.Repositories.PageInfo
+	}
+
+	tests := []struct {
+		testNum  int
+		opts     *graphql.PageOptions
+		expected string
+		pages    int
+		err      error
+	}{
+		{1, &graphql.PageOptions{CursorVariable: "cursor"}, "[{a} {b} {c} {d} {e}]", 3, nil},
+		{2, &graphql.PageOptions{CursorVariable: "cursor", MaxPages: 2}, "[{a} {b} {c} {d}]", 2, httpclient.ErrorMaxPages},
+		// The server ignores the default "after" variable so the first page repeats until the limit is reached.
+		{3, &graphql.PageOptions{MaxPages: 2}, "[{a} {b} {a} {b}]", 2, httpclient.ErrorMaxPages},
+	}
+
+	for _, test := range tests {
+		p := graphql.Paginate(context.Background(), client, query, map[string]interface{}{"first": 2}, connection, test.opts)
+
+		items, err := p.All()
+		if fmt.Sprint(items) != test.expected || p.Pages() != test.pages || !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Errorf("\nTest: %d\nExpected: %s, %d pages, %v\nGot.....: %v, %d pages, %v",
+				test.testNum, test.expected, test.pages, test.err, items, p.Pages(), err)
+		}
+	}
+}
+
+func TestPaginateCancel(t *testing.T) {
+	client := newClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := graphql.Paginate(ctx, client, "query { viewer { repositories { nodes { name } } } }", nil,
+		func(data repos) ([]repo, graphql.PageInfo) {
+			return data.Viewer.Repositories.Items(), data.Viewer.Repositories.PageInfo
+		}, nil)
+
+	if p.Next() || !errors.Is(p.Err(), context.Canceled) || p.Pages() != 0 {
+		t.Errorf("expected cancelled context to stop pagination, got %v after %d pages", p.Err(), p.Pages())
+	}
+}
+
+func TestPaginateNilContext(t *testing.T) {
+	client := newClient(t)
+	query := "query($cursor: String) { viewer { repositories(first: 2, after: $cursor) { edges { node { name } } } } }"
+
+	p := graphql.Paginate(nil, client, query, nil, // nolint:staticcheck // testing a nil context
+		func(data repos) ([]repo, graphql.PageInfo) {
+			return data.Viewer.Repositories.Items(), data.Viewer.Repositories.PageInfo
+		}, &graphql.PageOptions{CursorVariable: "cursor"})
+
+	if items, err := p.All(); err != nil || fmt.Sprint(items) != "[{a} {b} {c} {d} {e}]" {
+		t.Errorf("expected nil context to be accepted, got %v, %v", items, err)
+	}
+}
@@ -3,14 +3,13 @@ package httpclient
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -30,13 +29,20 @@ var (
 	ErrorInvalidURL         = errors.New("url is invalid")
 	ErrorReadingRespBody    = errors.New("error reading response body")
 	ErrorRequestFailed      = errors.New("error making request")
-	ErrorRequestBodyInvalid = errors.New("failed to convert request body data to JSON")
-
-	tr             *http.Transport // nolint:gochecknoglobals // ok
-	DefaultTimeout time.Duration   // nolint:gochecknoglobals // ok
-	Post           = "POST"        // nolint:gochecknoglobals // ok
-	Delete         = "DELETE"      // nolint:gochecknoglobals // ok
-	Get            = "GET"         // nolint:gochecknoglobals // ok
+	ErrorRequestBodyInvalid = errors.New("failed to encode request body data")
+	ErrorInvalidReqResp     = errors.New("request was not created by NewReqResp")
+
+	DefaultTimeout = time.Second * thirty // nolint:gochecknoglobals // ok
+	Post           = "POST"               // nolint:gochecknoglobals // ok
+	Delete         = "DELETE"             // nolint:gochecknoglobals // ok
+	Get            = "GET"                // nolint:gochecknoglobals // ok
+	Put            = "PUT"                // nolint:gochecknoglobals // ok
+	Patch          = "PATCH"              // nolint:gochecknoglobals // ok
+	Head           = "HEAD"               // nolint:gochecknoglobals // ok
+	Options        = "OPTIONS"            // nolint:gochecknoglobals // ok
+
+	sharedTransport     http.RoundTripper // nolint:gochecknoglobals // ok
+	sharedTransportOnce sync.Once         // nolint:gochecknoglobals // ok
 )
 
 func readingResponseBodyError(msg string) error {
@@ -51,14 +57,22 @@ func requestBodyError(msg string) error {
 	return fmt.Errorf("%w: %s", ErrorRequestBodyInvalid, msg)
 }
 
-func init() { // nolint:gochecknoinits // ok
-	tr = &http.Transport{
+// newTransport returns a transport with the default settings.
+func newTransport() *http.Transport {
+	return &http.Transport{
 		Proxy:               http.ProxyFromEnvironment,
 		MaxIdleConns:        oneHundred,
 		MaxIdleConnsPerHost: oneHundred,
 	}
+}
 
-	DefaultTimeout = time.Second * thirty
+// defaultTransport returns the transport shared by requests created without a client or transport.
+func defaultTransport() http.RoundTripper {
+	sharedTransportOnce.Do(func() {
+		sharedTransport = newTransport()
+	})
+
+	return sharedTransport
 }
 
 // Header is a type used to store header field name/value pairs when sending HTTPS requests.
@@ -66,11 +80,10 @@ type Header map[string]string
 
 // reqResp hold information relating to an HTTPS request and response.
 type reqResp struct {
-	ReqResp
 	ctx          context.Context
 	logger       logr.Logger
 	client       *http.Client
-	transport    *http.Transport
+	transport    http.RoundTripper
 	url          *url.URL
 	method       *string
 	timeout      *time.Duration
@@ -78,6 +91,24 @@ type reqResp struct {
 	resp         *http.Response
 	respText     *string
 	headerFields Header
+	retryPolicy  RetryPolicy
+	attempts     int
+	bodyData     []byte
+	contentType  string
+	bodyEncoded  bool
+	statusCheck  StatusCheck
+	latency      time.Duration
+	auth         Authenticator
+	reauthorized bool
+	reauthSent   int // Attempt resending the request with fresh credentials, zero if none.
+	breaker      *CircuitBreaker
+	logOptions   *LogOptions
+	limiter      *RateLimiter
+	cache        *Cache
+	cacheable    bool
+	cacheEntry   *cacheEntry
+	cacheStatus  CacheStatus
+	metrics      Metrics
 }
 
 type ReqResp interface {
@@ -86,8 +117,33 @@ type ReqResp interface {
 	CloseBody()
 	RespBody() string
 	ResponseCode() int
+	RespHeader() http.Header
+	Metadata() *ResponseMetadata
+	SetRetryPolicy(policy RetryPolicy)
+	SetStatusCheck(check StatusCheck)
+	SetAuth(auth Authenticator)
+	SetCircuitBreaker(breaker *CircuitBreaker)
+	SetLogOptions(opts *LogOptions)
+	SetRateLimiter(limiter *RateLimiter)
+	SetCache(cache *Cache)
+	SetMetrics(metrics Metrics)
+}
+
+// asReqResp returns the implementation of a request created by NewReqResp.
+func asReqResp(r ReqResp) (*reqResp, error) {
+	impl, ok := r.(*reqResp)
+	if !ok || impl == nil {
+		return nil, ErrorInvalidReqResp
+	}
+
+	return impl, nil
 }
 
+// NewReqResp returns a ReqResp for a request, nil arguments other than the url are replaced by defaults.
+// A body implementing Body encodes itself, []byte and io.Reader bodies are sent as is, url.Values are form encoded
+// and anything else is sent as JSON. An *Upload is streamed rather than held in memory. A body is sent with any method,
+// including GET and DELETE, and a nil body sends no body with any method, including POST.
+// Use a Client to create requests sharing configuration.
 func NewReqResp(ctx context.Context, url *url.URL, method *string, body interface{}, header Header,
 	timeout *time.Duration, logger logr.Logger, client *http.Client, transport http.RoundTripper) (ReqResp, error) {
 	if url == nil {
@@ -99,7 +155,7 @@ func NewReqResp(ctx context.Context, url *url.URL, method *string, body interfac
 	}
 
 	if transport == nil {
-		transport = tr
+		transport = defaultTransport()
 	}
 
 	if client == nil {
@@ -125,7 +181,7 @@ func NewReqResp(ctx context.Context, url *url.URL, method *string, body interfac
 	r := reqResp{
 		ctx:          ctx,
 		logger:       logger,
-		transport:    tr,
+		transport:    transport,
 		client:       client,
 		url:          url,
 		method:       method,
@@ -133,6 +189,9 @@ func NewReqResp(ctx context.Context, url *url.URL, method *string, body interfac
 		body:         body,
 		headerFields: header,
 		respText:     nil,
+		retryPolicy:  NewBackoffPolicy(),
+		statusCheck:  Is2xx,
+		logOptions:   &LogOptions{},
 	}
 
 	return &r, nil
@@ -152,28 +211,69 @@ func (r *reqResp) CloseBody() {
 	}
 }
 
-// HTTPreq creates an HTTP client and sends a request. The response is held in reqResp.RespText.
-func (r *reqResp) HTTPreq() error { // nolint:funlen,gocognit,gocyclo // ok
-	var err error
+// SetRetryPolicy sets the policy used to decide if a request is retried, nil disables retries.
+func (r *reqResp) SetRetryPolicy(policy RetryPolicy) {
+	if policy == nil {
+		policy = NoRetryPolicy()
+	}
 
-	r.client.Timeout = *r.timeout
+	r.retryPolicy = policy
+}
 
-	// r.logger.V(logging.TraceLevel).Info("Request", "method", r.method, "url", r.url) //.
+// SetStatusCheck sets the function used to decide which response status codes are successful, nil accepts 2xx codes.
+func (r *reqResp) SetStatusCheck(check StatusCheck) {
+	if check == nil {
+		check = Is2xx
+	}
 
-	var inputJSON io.ReadCloser
+	r.statusCheck = check
+}
 
-	if *r.method == Post {
-		jsonBytes, e := json.Marshal(r.body)
-		if e != nil {
-			return requestBodyError(e.Error())
-		}
+// SetAuth sets the Authenticator used to add credentials to each attempt, nil sends no credentials.
+func (r *reqResp) SetAuth(auth Authenticator) {
+	r.auth = auth
+}
+
+// SetCircuitBreaker sets the CircuitBreaker consulted before each attempt, nil disables it.
+func (r *reqResp) SetCircuitBreaker(breaker *CircuitBreaker) {
+	r.breaker = breaker
+}
+
+// SetRateLimiter sets the RateLimiter waited on before each attempt, nil disables rate limiting.
+func (r *reqResp) SetRateLimiter(limiter *RateLimiter) {
+	r.limiter = limiter
+}
 
-		inputJSON = ioutil.NopCloser(bytes.NewReader(jsonBytes))
+// closeRequestBody closes the body of a request that is not sent, the client closes the body of requests it sends.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close() // nolint:errcheck,gosec // ok
 	}
+}
 
-	httpReq, err := http.NewRequestWithContext(r.ctx, *r.method, r.url.String(), inputJSON)
+// setHeader sets a request header.
+func (r *reqResp) setHeader(name, value string) {
+	r.headerFields[name] = value
+}
+
+// newRequest creates the HTTP request, a new request is created for each attempt so the body is resent.
+func (r *reqResp) newRequest() (*http.Request, error) {
+	body, contentType, err := r.requestBody()
 	if err != nil {
-		return readingResponseBodyError(err.Error())
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(r.ctx, *r.method, r.url.String(), body)
+	if err != nil {
+		return nil, readingResponseBodyError(err.Error())
+	}
+
+	if upload, ok := r.body.(*Upload); ok && upload.Size > 0 {
+		httpReq.ContentLength = upload.Size
+	}
+
+	if len(contentType) > 0 {
+		httpReq.Header.Set(ContentType, contentType)
 	}
 
 	for k, v := range r.headerFields {
@@ -182,50 +282,211 @@ func (r *reqResp) HTTPreq() error { // nolint:funlen,gocognit,gocyclo // ok
 		}
 	}
 
-	retries := 30
-	seconds := 1
+	if r.auth != nil {
+		if err := r.auth.Authenticate(httpReq); err != nil {
+			return nil, err
+		}
+	}
+
+	return httpReq, nil
+}
+
+// requestBody returns the body for an attempt and its content type. An *Upload is opened for each attempt, other
+// bodies are encoded once.
+func (r *reqResp) requestBody() (io.Reader, string, error) {
+	if upload, ok := r.body.(*Upload); ok {
+		return upload.reader()
+	}
+
+	if !r.bodyEncoded {
+		data, contentType, err := encodeBody(r.body)
+		if err != nil {
+			return nil, "", requestBodyError(err.Error())
+		}
+
+		r.bodyData, r.contentType, r.bodyEncoded = data, contentType, true
+	}
+
+	if r.bodyData == nil {
+		return nil, r.contentType, nil
+	}
+
+	return bytes.NewReader(r.bodyData), r.contentType, nil
+}
+
+// HTTPreq creates an HTTP client and sends a request, retrying as directed by the retry policy.
+// The response is held in reqResp.RespText. A *StatusError is returned if the response status is not accepted
+// by the status check.
+func (r *reqResp) HTTPreq() error {
+	return r.send(true)
+}
+
+// send sends the request, retrying as directed by the retry policy. If buffer is false the body of a successful
+// response is left unread in reqResp.resp for the caller to consume and close.
+func (r *reqResp) send(buffer bool) (err error) { // nolint:gocognit,gocyclo // ok
+	r.client.Timeout = *r.timeout
+
 	start := time.Now()
+	r.reauthorized, r.reauthSent = false, 0
+	r.cacheable, r.cacheEntry, r.cacheStatus = false, nil, CacheMiss
+
+	defer func() {
+		r.latency = time.Since(start)
+
+		if r.metrics != nil {
+			r.observeRequest(err)
+		}
+	}()
+
+	for r.attempts = 1; ; r.attempts++ {
+		httpReq, err := r.newRequest()
+		if err != nil {
+			return err
+		}
+
+		if r.cache != nil {
+			if r.attempts == 1 && r.cacheLookup(httpReq, buffer) {
+				closeRequestBody(httpReq)
+
+				break
+			}
+
+			if r.cacheEntry != nil {
+				r.cacheEntry.conditional(httpReq)
+			}
+		}
+
+		if r.limiter != nil {
+			if err := r.limiter.Wait(r.ctx, r.url.Host); err != nil {
+				closeRequestBody(httpReq)
+
+				return err
+			}
+		}
+
+		if r.breaker != nil {
+			if err := r.breaker.allow(r.url.Host); err != nil {
+				closeRequestBody(httpReq)
+
+				return err
+			}
+		}
+
+		var reused int32
+
+		if r.metrics != nil {
+			httpReq = traceConn(httpReq, &reused)
+		}
+
+		r.respText = nil
+		r.logRequest(httpReq)
+		attemptStart := time.Now()
 
-	for {
 		r.resp, err = r.client.Do(httpReq) // nolint:bodyclose // ok
-		if err != nil {                    // nolint:nestif // ok
-			if strings.Contains(err.Error(), "connection refused") ||
-				strings.Contains(err.Error(), "http2: no cached connection was available") ||
-				strings.Contains(err.Error(), "net/http: TLS handshake timeout") ||
-				strings.Contains(err.Error(), "i/o timeout") ||
-				strings.Contains(err.Error(), "unexpected EOF") ||
-				strings.Contains(err.Error(), "Client.Timeout exceeded while awaiting headers") {
-				time.Sleep(time.Second * time.Duration(int64(seconds)))
 
-				retries--
+		if r.breaker != nil {
+			r.breaker.record(r.url.Host, r.resp, err)
+		}
+
+		if r.limiter != nil {
+			r.limiter.observe(r.url.Host, r.resp)
+		}
+
+		readFailed := false
+
+		if err == nil && buffer {
+			err = r.getRespBody()
+			readFailed = err != nil
+		}
 
-				seconds += seconds
+		r.logResponse(time.Since(attemptStart), err)
 
-				if seconds > ten {
-					seconds = one
-				}
+		if r.metrics != nil {
+			r.observeAttempt(time.Since(attemptStart), &reused, err)
+		}
 
-				if retries > 0 || time.Since(start) > *r.timeout {
-					r.logger.Error(err, "server failed to respond", "url", r.url)
+		if readFailed || (err != nil && r.ctx.Err() != nil) {
+			return err
+		}
 
-					continue
-				}
+		if err == nil && r.cache != nil {
+			r.updateCache(httpReq, attemptStart, buffer)
+		}
+
+		if err == nil && r.reauthorize() {
+			if !buffer {
+				r.discardBody()
 			}
 
+			r.logger.Info("retrying request with fresh credentials", "url", r.redactedURL(r.url), "attempt", r.attempts)
+			r.reauthSent = r.attempts + 1
+
+			continue
+		}
+
+		var retryErr error
+		if err != nil {
+			retryErr = &AttemptError{Request: httpReq, Err: err}
+		}
+
+		delay, retry := r.retryPolicy.Retry(r.retryAttempts(), time.Since(start), r.resp, retryErr)
+		if !retry {
+			if err != nil {
+				return err
+			}
+
+			break
+		}
+
+		if err != nil {
+			r.logger.Error(r.redactedError(err), "server failed to respond", "url", r.redactedURL(r.url), "attempt", r.attempts,
+				"delay", delay)
+		} else {
+			if !buffer {
+				r.discardBody()
+			}
+
+			r.logger.Info("retrying request", "url", r.redactedURL(r.url), "status", r.resp.StatusCode, "attempt", r.attempts,
+				"delay", delay)
+		}
+
+		if err := sleepContext(r.ctx, delay); err != nil {
 			return err
 		}
+	}
 
+	if r.statusCheck(r.resp.StatusCode) {
+		return nil
+	}
+
+	if !buffer {
 		if err := r.getRespBody(); err != nil {
 			return err
 		}
+	}
 
-		if r.resp.StatusCode == 200 || (r.resp.StatusCode == 201 && *r.method == Post) ||
-			(r.resp.StatusCode == 204 && *r.method == Delete) {
-			return nil
-		}
+	return newStatusError(r.resp, r.RespBody())
+}
 
-		return requestError(fmt.Sprintf("failed: %s %s", r.resp.Status, r.RespBody()))
+// reauthorize returns true if the request was rejected with a 401 status and should be sent again with fresh
+// credentials, this is done once per request regardless of the retry policy.
+func (r *reqResp) reauthorize() bool {
+	if r.auth == nil || r.reauthorized || r.resp.StatusCode != http.StatusUnauthorized {
+		return false
 	}
+
+	r.reauthorized = true
+
+	return r.auth.Invalidate()
+}
+
+// retryAttempts returns the number of attempts made, not counting a resend with fresh credentials.
+func (r *reqResp) retryAttempts() int {
+	if r.reauthSent > 0 && r.attempts >= r.reauthSent {
+		return r.attempts - 1
+	}
+
+	return r.attempts
 }
 
 // getRespBody is used to obtain the response body as a string.
@@ -243,6 +504,15 @@ func (r *reqResp) getRespBody() error {
 	return nil
 }
 
+// discardBody reads and closes the response body so the connection can be reused.
+func (r *reqResp) discardBody() {
+	if _, err := io.Copy(ioutil.Discard, r.resp.Body); err != nil {
+		r.logger.Error(err, "failed to read response body")
+	}
+
+	r.CloseBody()
+}
+
 // RespBody is used to return the response body as a string.
 func (r *reqResp) RespBody() string {
 	if r.respText == nil {
@@ -256,7 +526,21 @@ func (r *reqResp) RespBody() string {
 	return *r.respText
 }
 
+// response returns the HTTP response, nil if no response has been received.
+func (r *reqResp) response() *http.Response {
+	return r.resp
+}
+
 // RespCode is used to return the response code.
 func (r *reqResp) RespCode() int {
+	return r.ResponseCode()
+}
+
+// ResponseCode is used to return the response code, zero if no response has been received.
+func (r *reqResp) ResponseCode() int {
+	if r.resp == nil {
+		return 0
+	}
+
 	return r.resp.StatusCode
 }
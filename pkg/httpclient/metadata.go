@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ResponseMetadata holds information about a response and how it was obtained.
+type ResponseMetadata struct {
+	StatusCode int                  // Response status code.
+	Header     http.Header          // Response headers.
+	FinalURL   *url.URL             // URL of the final request, after following any redirects.
+	Proto      string               // Protocol of the response, e.g. "HTTP/2.0".
+	TLS        *tls.ConnectionState // TLS connection state, nil for unencrypted connections.
+	Attempts   int                  // Number of attempts made, including retries and any resend with fresh credentials.
+	Latency    time.Duration        // Total time taken, including retries and delays between them.
+	Cache      CacheStatus          // Whether the response was served from the cache.
+}
+
+// RespHeader is used to return the response headers, nil if no response has been received.
+func (r *reqResp) RespHeader() http.Header {
+	if r.resp == nil {
+		return nil
+	}
+
+	return r.resp.Header
+}
+
+// Metadata is used to return information about the response, the status, headers, URL, protocol and TLS state are
+// only set if a response has been received.
+func (r *reqResp) Metadata() *ResponseMetadata {
+	metadata := &ResponseMetadata{Attempts: r.attempts, Latency: r.latency, Cache: r.cacheStatus}
+
+	if r.resp == nil {
+		return metadata
+	}
+
+	metadata.StatusCode = r.resp.StatusCode
+	metadata.Header = r.resp.Header
+	metadata.Proto = r.resp.Proto
+	metadata.TLS = r.resp.TLS
+
+	if r.resp.Request != nil {
+		metadata.FinalURL = r.resp.Request.URL
+	}
+
+	return metadata
+}
+
+// ParseLinks parses the RFC 8288 Link headers, returning a map of relation types to URLs, e.g. "next" to the URL of
+// the next page of results. URLs may contain commas and semicolons, as may quoted parameter values.
+func ParseLinks(header http.Header) map[string]string {
+	links := map[string]string{}
+
+	for _, value := range header.Values("Link") {
+		for len(value) > 0 {
+			value = strings.TrimLeft(value, " \t,")
+			if !strings.HasPrefix(value, "<") {
+				// Not a link, skip to the next one.
+				_, value = cutUnquoted(value, ',')
+
+				continue
+			}
+
+			end := strings.IndexByte(value, '>')
+			if end < 0 {
+				break
+			}
+
+			target := value[1:end]
+
+			var params string
+
+			params, value = cutUnquoted(value[end+1:], ',')
+			for len(params) > 0 {
+				var param string
+
+				param, params = cutUnquoted(params, ';')
+
+				name, rels, found := strings.Cut(strings.TrimSpace(param), "=")
+				if !found || strings.ToLower(strings.TrimSpace(name)) != "rel" {
+					continue
+				}
+
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(rels), `"`)) {
+					links[strings.ToLower(rel)] = target
+				}
+			}
+		}
+	}
+
+	return links
+}
+
+// cutUnquoted slices s around the first sep that is not in a quoted string, returning the text before and after it.
+func cutUnquoted(s string, sep byte) (string, string) {
+	quoted := false
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			return s[:i], s[i+1:]
+		}
+	}
+
+	return s, ""
+}
@@ -0,0 +1,99 @@
+package httpclient_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+)
+
+func TestMetadata(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+
+			return
+		}
+
+		w.Header().Set("Link", `<https://example.com/items?page=2>; rel="next"`)
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/old")
+	if err != nil {
+		t.Fatalf("failed to parse url, %s", err)
+	}
+
+	r, err := httpclient.NewReqResp(context.Background(), u, nil, nil, nil, nil, nil, server.Client(), nil)
+	if err != nil {
+		t.Fatalf("failed to create request, %s", err)
+	}
+
+	if r.ResponseCode() != 0 || r.RespHeader() != nil || r.Metadata().Attempts != 0 {
+		t.Fatalf("expected empty metadata before request, got %+v", r.Metadata())
+	}
+
+	if err := r.HTTPreq(); err != nil {
+		t.Fatalf("request failed, %s", err)
+	}
+
+	metadata := r.Metadata()
+
+	if r.ResponseCode() != http.StatusOK || metadata.StatusCode != http.StatusOK || metadata.Attempts != 1 || metadata.Latency <= 0 ||
+		metadata.FinalURL.Path != "/new" || metadata.Proto != "HTTP/1.1" || metadata.TLS == nil || !metadata.TLS.HandshakeComplete {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+
+	if links := httpclient.ParseLinks(r.RespHeader()); links["next"] != "https://example.com/items?page=2" {
+		t.Errorf("unexpected links: %v", links)
+	}
+}
+
+func TestMetadataAttempts(t *testing.T) {
+	server := mockserver.New(t)
+	server.Expect(httpclient.Get, "/").Respond(statusResponses(nil, http.StatusServiceUnavailable, http.StatusOK)...)
+
+	r := newGet(t, server.URL)
+	r.SetRetryPolicy(fastPolicy())
+
+	if err := r.HTTPreq(); err != nil {
+		t.Fatalf("request failed, %s", err)
+	}
+
+	if metadata := r.Metadata(); metadata.Attempts != 2 || metadata.TLS != nil {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+}
+
+func TestParseLinks(t *testing.T) {
+	tests := []struct {
+		testNum  int
+		values   []string
+		expected map[string]string
+	}{
+		{1, nil, map[string]string{}},
+		{2, []string{`<https://a/2>; rel="next", <https://a/9>; rel="last"`}, map[string]string{"next": "https://a/2", "last": "https://a/9"}},
+		{3, []string{`<https://a/1>; rel="prev first"`, `<https://a/3>;rel=next`}, map[string]string{"prev": "https://a/1", "first": "https://a/1", "next": "https://a/3"}},
+		{4, []string{`https://a/2; rel="next"`, `<https://a/3>; title="x"`}, map[string]string{}},
+		{5, []string{`<https://api/x?fields=a,b&page=2>; rel="next", <https://api/x?fields=a,b;c&page=9>; rel="last"`},
+			map[string]string{"next": "https://api/x?fields=a,b&page=2", "last": "https://api/x?fields=a,b;c&page=9"}},
+		{6, []string{`<https://a/2>; title="a, b; c"; rel="next", junk, <https://a/1>; rel=prev`}, map[string]string{"next": "https://a/2", "prev": "https://a/1"}},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		for _, value := range test.values {
+			header.Add("Link", value)
+		}
+
+		if result := httpclient.ParseLinks(header); fmt.Sprint(result) != fmt.Sprint(test.expected) {
+			t.Errorf("\nTest: %d\nExpected: %v\nGot.....: %v", test.testNum, test.expected, result)
+		}
+	}
+}
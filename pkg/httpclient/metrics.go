@@ -0,0 +1,99 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+const maxStatusCode = 599
+
+type (
+	// Metrics is the interface used to record metrics about requests, implementations must be safe for concurrent
+	// use. See the prometheus package for a Prometheus implementation.
+	Metrics interface {
+		// ObserveAttempt is called after each attempt to send a request.
+		ObserveAttempt(attempt AttemptInfo)
+		// ObserveRequest is called when a request completes, after any retries.
+		ObserveRequest(request RequestInfo)
+	}
+
+	// AttemptInfo describes an attempt to send a request.
+	AttemptInfo struct {
+		Host       string
+		Method     string
+		StatusCode int           // Response status code, zero if no response was received.
+		Err        error         // Error sending the request, nil if a response was received.
+		Duration   time.Duration // Time taken to receive the response headers, or the body if it is buffered.
+		ConnReused bool          // True if the request was sent on a previously used connection.
+		Reauth     bool          // True if the attempt resent the request with fresh credentials after a 401 response.
+	}
+
+	// RequestInfo describes a completed request.
+	RequestInfo struct {
+		Host       string
+		Method     string
+		StatusCode int           // Status code of the final response, zero if no response was received.
+		Err        error         // Error returned by the request.
+		Attempts   int           // Number of attempts made, including retries and any Reauth attempt.
+		Reauth     bool          // True if the request was resent with fresh credentials, this is not a retry.
+		Latency    time.Duration // Total time taken, including retries and delays between them.
+		Cache      CacheStatus   // Whether the response was served from the cache.
+	}
+)
+
+// StatusClass returns the class of a status code, e.g. "2xx", or "none" if no response was received.
+func StatusClass(statusCode int) string {
+	if statusCode < http.StatusContinue || statusCode > maxStatusCode {
+		return "none"
+	}
+
+	return fmt.Sprintf("%dxx", statusCode/oneHundred)
+}
+
+// SetMetrics sets the Metrics used to record the request's attempts and outcome, nil disables them.
+func (r *reqResp) SetMetrics(metrics Metrics) {
+	r.metrics = metrics
+}
+
+// traceConn returns the request with a trace recording whether its connection was reused.
+func traceConn(req *http.Request, reused *int32) *http.Request {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.StoreInt32(reused, 1)
+			}
+		},
+	}
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// observeAttempt records an attempt.
+func (r *reqResp) observeAttempt(duration time.Duration, reused *int32, err error) {
+	r.metrics.ObserveAttempt(AttemptInfo{
+		Host:       r.url.Host,
+		Method:     *r.method,
+		StatusCode: r.ResponseCode(),
+		Err:        err,
+		Duration:   duration,
+		ConnReused: atomic.LoadInt32(reused) == 1,
+		Reauth:     r.attempts == r.reauthSent,
+	})
+}
+
+// observeRequest records the outcome of the request.
+func (r *reqResp) observeRequest(err error) {
+	r.metrics.ObserveRequest(RequestInfo{
+		Host:       r.url.Host,
+		Method:     *r.method,
+		StatusCode: r.ResponseCode(),
+		Err:        err,
+		Attempts:   r.attempts,
+		Reauth:     r.reauthSent > 0 && r.attempts >= r.reauthSent,
+		Latency:    r.latency,
+		Cache:      r.cacheStatus,
+	})
+}
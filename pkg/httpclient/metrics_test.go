@@ -0,0 +1,168 @@
+package httpclient_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+)
+
+// recordMetrics is a Metrics implementation recording a description of each observation.
+type recordMetrics struct {
+	mutex        sync.Mutex
+	observations []string
+}
+
+func (m *recordMetrics) ObserveAttempt(attempt httpclient.AttemptInfo) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.observations = append(m.observations, fmt.Sprintf("attempt %s %d %t %t %t",
+		attempt.Method, attempt.StatusCode, attempt.ConnReused, attempt.Err != nil, attempt.Reauth))
+}
+
+func (m *recordMetrics) ObserveRequest(request httpclient.RequestInfo) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.observations = append(m.observations, fmt.Sprintf("request %s %d %d %s %t %t",
+		request.Method, request.StatusCode, request.Attempts, request.Cache, request.Err != nil, request.Reauth))
+}
+
+// metricsRequests sends requests to a server responding 503, 200 and then 404, one of which is served from the
+// cache, and to a closed server. It returns the hosts of the servers.
+func metricsRequests(t *testing.T, metrics httpclient.Metrics) (string, string) {
+	server := mockserver.New(t)
+	server.Expect("", "/").Respond(statusResponses(http.Header{"Cache-Control": {"max-age=60"}},
+		http.StatusServiceUnavailable, http.StatusOK, http.StatusNotFound)...)
+	server.Expect(httpclient.Post, "/other").Respond(mockserver.Status(http.StatusNotFound))
+
+	closed := mockserver.New(t)
+	closed.Close()
+
+	client, err := httpclient.NewClient(httpclient.WithMetrics(metrics), httpclient.WithRetryPolicy(fastPolicy()),
+		httpclient.WithCache(httpclient.NewCache(nil)))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	ctx := context.Background()
+
+	client.Get(ctx, server.URL).Do()                  // nolint:errcheck // ok
+	client.Get(ctx, server.URL).Do()                  // nolint:errcheck // ok
+	client.Delete(ctx, server.URL).Do()               // nolint:errcheck // ok
+	client.Get(ctx, closed.URL).RetryPolicy(nil).Do() // nolint:errcheck // ok
+	client.Post(ctx, server.URL+"/other", nil).Do()   // nolint:errcheck // ok
+
+	return strings.TrimPrefix(server.URL, "http://"), strings.TrimPrefix(closed.URL, "http://")
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := &recordMetrics{}
+	metricsRequests(t, metrics)
+
+	expected := []string{
+		"attempt GET 503 false false false",
+		"attempt GET 200 true false false",
+		"request GET 200 2 miss false false",
+		"request GET 200 1 hit false false",
+		"attempt DELETE 404 true false false",
+		"request DELETE 404 1 miss true false",
+		"attempt GET 0 false true false",
+		"request GET 0 1 miss true false",
+		"attempt POST 404 true false false",
+		"request POST 404 1 miss true false",
+	}
+
+	if fmt.Sprint(metrics.observations) != fmt.Sprint(expected) {
+		t.Errorf("\nExpected: %v\nGot.....: %v", expected, metrics.observations)
+	}
+}
+
+// refreshAuth is an Authenticator whose credentials can be refreshed once.
+type refreshAuth struct {
+	refreshed bool
+}
+
+func (a *refreshAuth) Authenticate(*http.Request) error {
+	return nil
+}
+
+func (a *refreshAuth) Invalidate() bool {
+	refreshed := a.refreshed
+	a.refreshed = true
+
+	return !refreshed
+}
+
+func TestMetricsAttempts(t *testing.T) {
+	reauth := mockserver.New(t)
+	reauth.Expect(httpclient.Get, "/").Respond(statusResponses(nil, http.StatusUnauthorized, http.StatusServiceUnavailable, http.StatusOK)...)
+
+	truncated := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		fmt.Fprint(w, "short")
+	}))
+	defer truncated.Close()
+
+	metrics := &recordMetrics{}
+
+	policy := fastPolicy()
+	policy.MaxAttempts = 2
+
+	client, err := httpclient.NewClient(httpclient.WithMetrics(metrics), httpclient.WithRetryPolicy(policy),
+		httpclient.WithAuth(&refreshAuth{}))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	// The resend with fresh credentials is not counted as a retry so a single retry is allowed after it.
+	if _, err := client.Get(context.Background(), reauth.URL).Do(); err != nil {
+		t.Errorf("expected request to succeed after reauthorization and a retry, %s", err)
+	}
+
+	if _, err := client.Get(context.Background(), truncated.URL).Do(); err == nil {
+		t.Errorf("expected truncated response body to fail")
+	}
+
+	expected := []string{
+		"attempt GET 401 false false false",
+		"attempt GET 503 true false true",
+		"attempt GET 200 true false false",
+		"request GET 200 3 miss false true",
+		"attempt GET 200 false true false",
+		"request GET 200 1 miss true false",
+	}
+
+	if fmt.Sprint(metrics.observations) != fmt.Sprint(expected) {
+		t.Errorf("\nExpected: %v\nGot.....: %v", expected, metrics.observations)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	tests := []struct {
+		testNum  int
+		code     int
+		expected string
+	}{
+		{1, 0, "none"},
+		{2, 101, "1xx"},
+		{3, 200, "2xx"},
+		{4, 304, "3xx"},
+		{5, 429, "4xx"},
+		{6, 599, "5xx"},
+		{7, 600, "none"},
+	}
+
+	for _, test := range tests {
+		if got := httpclient.StatusClass(test.code); got != test.expected {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %s", test.testNum, test.expected, got)
+		}
+	}
+}
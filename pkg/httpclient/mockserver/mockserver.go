@@ -0,0 +1,387 @@
+// Package mockserver provides a scriptable HTTP server for testing code using httpclient. Tests declare the requests
+// they expect and the responses to send, including sequences of responses, latency and connection resets, then
+// verify all the expectations were met.
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type (
+	// Server is a mock HTTP server, it is safe for concurrent use.
+	Server struct {
+		// URL is the base URL of the server.
+		URL          string
+		t            testing.TB
+		server       *httptest.Server
+		mutex        sync.Mutex
+		expectations []*Expectation
+		requests     []*Request
+		unexpected   []string
+	}
+
+	// Expectation is an expected request and the responses to send to it, it should be configured before requests
+	// are sent.
+	Expectation struct {
+		mutex     *sync.Mutex
+		method    string
+		path      string
+		header    http.Header
+		matchers  []func(req *Request) bool
+		responses []Response
+		times     int
+		calls     int
+	}
+
+	// Request is a request received by the server.
+	Request struct {
+		Method string
+		Path   string
+		Query  string
+		Header http.Header
+		Body   []byte
+	}
+
+	// Response is a scripted response.
+	Response struct {
+		Status int
+		Header http.Header
+		Body   string
+		Delay  time.Duration // Time to wait before responding.
+		Reset  bool          // Set to reset the connection instead of responding.
+	}
+)
+
+// New starts a Server, it is closed when the test completes.
+func New(t testing.TB) *Server {
+	s := &Server{t: t}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Expect adds an expected request with the given method and path, an empty method matches any method.
+// By default the expectation is met once each of its responses has been sent.
+func (s *Server) Expect(method, path string) *Expectation {
+	e := &Expectation{mutex: &s.mutex, method: method, path: path, header: http.Header{}}
+
+	s.mutex.Lock()
+	s.expectations = append(s.expectations, e)
+	s.mutex.Unlock()
+
+	return e
+}
+
+// Requests returns the requests received by the server.
+func (s *Server) Requests() []*Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]*Request{}, s.requests...)
+}
+
+// Unmet returns a description of each expectation that has not been met and each unexpected request received.
+func (s *Server) Unmet() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unmet := append([]string{}, s.unexpected...)
+
+	for _, e := range s.expectations {
+		if !e.met() {
+			unmet = append(unmet, fmt.Sprintf("expected %s, got %d calls", e, e.calls))
+		}
+	}
+
+	return unmet
+}
+
+// Verify reports a test error for each expectation that has not been met and each unexpected request received.
+func (s *Server) Verify() {
+	s.t.Helper()
+
+	for _, problem := range s.Unmet() {
+		s.t.Errorf("mock server: %s", problem)
+	}
+}
+
+// serveHTTP responds to a request using the first matching expectation that has not received all its calls.
+// Unexpected requests receive a 501 response.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.t.Errorf("mock server: failed to read request body, %s", err)
+	}
+
+	req := &Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Header: r.Header.Clone(), Body: body}
+
+	response, ok := s.respond(req)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unexpected request: %s %s", r.Method, r.URL), http.StatusNotImplemented)
+
+		return
+	}
+
+	if response.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(response.Delay):
+		}
+	}
+
+	if response.Reset {
+		resetConnection(s.t, w)
+
+		return
+	}
+
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+
+	status := response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.WriteHeader(status)
+
+	if _, err := w.Write([]byte(response.Body)); err != nil {
+		s.t.Logf("mock server: failed to write response, %s", err)
+	}
+}
+
+// respond records the request and returns the response to send, false if the request is unexpected.
+func (s *Server) respond(req *Request) (Response, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests = append(s.requests, req)
+
+	for _, e := range s.expectations {
+		if !e.matches(req) || (e.times > 0 && e.calls >= e.times) {
+			continue
+		}
+
+		e.calls++
+
+		if len(e.responses) == 0 {
+			return Response{Status: http.StatusOK}, true
+		}
+
+		if e.calls > len(e.responses) {
+			return e.responses[len(e.responses)-1], true
+		}
+
+		return e.responses[e.calls-1], true
+	}
+
+	s.unexpected = append(s.unexpected, fmt.Sprintf("unexpected request %s %s", req.Method, req.Path))
+
+	return Response{}, false
+}
+
+// resetConnection closes the connection so the client sees a connection reset.
+func resetConnection(t testing.TB, w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		t.Errorf("mock server: connection cannot be reset")
+
+		return
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		t.Errorf("mock server: failed to hijack connection, %s", err)
+
+		return
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := tcpConn.SetLinger(0); err != nil {
+			t.Logf("mock server: failed to set linger, %s", err)
+		}
+	}
+
+	conn.Close()
+}
+
+// Header adds a header that requests must have.
+func (e *Expectation) Header(name, value string) *Expectation {
+	e.header.Add(name, value)
+
+	return e
+}
+
+// JSONBody requires the request body to be JSON equal to the value, which may be a JSON string or any value that
+// can be marshaled to JSON.
+func (e *Expectation) JSONBody(expected interface{}) *Expectation {
+	want, err := normalizeJSON(expected)
+
+	return e.Match(func(req *Request) bool {
+		got, gotErr := normalizeJSON(json.RawMessage(req.Body))
+
+		return err == nil && gotErr == nil && reflect.DeepEqual(got, want)
+	})
+}
+
+// Match adds a function that requests must match.
+func (e *Expectation) Match(matcher func(req *Request) bool) *Expectation {
+	e.matchers = append(e.matchers, matcher)
+
+	return e
+}
+
+// Respond sets the responses sent to successive matching requests, the last response is repeated.
+// With no responses matching requests receive an empty 200 response.
+func (e *Expectation) Respond(responses ...Response) *Expectation {
+	e.responses = append(e.responses, responses...)
+
+	return e
+}
+
+// Times sets the number of matching requests expected, further requests are unexpected unless matched by a later
+// expectation.
+func (e *Expectation) Times(times int) *Expectation {
+	e.times = times
+
+	return e
+}
+
+// Calls returns the number of requests matched.
+func (e *Expectation) Calls() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.calls
+}
+
+// String returns a description of the expectation.
+func (e *Expectation) String() string {
+	method := e.method
+	if len(method) == 0 {
+		method = "*"
+	}
+
+	return fmt.Sprintf("%s %s (%d calls)", method, e.path, e.expectedCalls())
+}
+
+// expectedCalls returns the number of calls required to meet the expectation.
+func (e *Expectation) expectedCalls() int {
+	switch {
+	case e.times > 0:
+		return e.times
+	case len(e.responses) > 0:
+		return len(e.responses)
+	default:
+		return 1
+	}
+}
+
+// met returns true if the expectation has been met.
+func (e *Expectation) met() bool {
+	if e.times > 0 {
+		return e.calls == e.times
+	}
+
+	return e.calls >= e.expectedCalls()
+}
+
+// matches returns true if the request matches the expectation.
+func (e *Expectation) matches(req *Request) bool {
+	if (len(e.method) > 0 && e.method != req.Method) || e.path != req.Path {
+		return false
+	}
+
+	for name, values := range e.header {
+		if strings.Join(req.Header.Values(name), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+
+	for _, matcher := range e.matchers {
+		if !matcher(req) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Status returns a response with the status code and no body.
+func Status(status int) Response {
+	return Response{Status: status}
+}
+
+// Text returns a text/plain response.
+func Text(status int, body string) Response {
+	return Response{Status: status, Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}}, Body: body}
+}
+
+// JSON returns a response with the value encoded as JSON, a string is sent as is.
+func JSON(status int, value interface{}) Response {
+	body, ok := value.(string)
+	if !ok {
+		data, err := json.Marshal(value)
+		if err != nil {
+			panic(fmt.Sprintf("mock server: failed to encode response, %s", err))
+		}
+
+		body = string(data)
+	}
+
+	return Response{Status: status, Header: http.Header{"Content-Type": {"application/json"}}, Body: body}
+}
+
+// Reset returns a response that resets the connection.
+func Reset() Response {
+	return Response{Reset: true}
+}
+
+// After returns the response with a delay before it is sent.
+func (r Response) After(delay time.Duration) Response {
+	r.Delay = delay
+
+	return r
+}
+
+// normalizeJSON returns a value decoded from its JSON encoding so values can be compared.
+func normalizeJSON(value interface{}) (interface{}, error) {
+	var data []byte
+
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case json.RawMessage:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+
+	var result interface{}
+	err := json.Unmarshal(data, &result)
+
+	return result, err
+}
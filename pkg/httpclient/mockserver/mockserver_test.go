@@ -0,0 +1,163 @@
+package mockserver_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+)
+
+func fastPolicy() *httpclient.BackoffPolicy {
+	policy := httpclient.NewBackoffPolicy()
+	policy.InitialInterval = time.Millisecond
+	policy.MaxInterval = 5 * time.Millisecond
+
+	return policy
+}
+
+func newClient(t *testing.T, baseURL string) *httpclient.Client {
+	client, err := httpclient.NewClient(httpclient.WithBaseURL(baseURL), httpclient.WithRetryPolicy(fastPolicy()),
+		httpclient.WithTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	return client
+}
+
+func TestRetrySequences(t *testing.T) {
+	server := mockserver.New(t)
+	client := newClient(t, server.URL)
+
+	server.Expect(httpclient.Get, "/flaky").Respond(
+		mockserver.Status(http.StatusServiceUnavailable),
+		mockserver.Status(http.StatusServiceUnavailable),
+		mockserver.JSON(http.StatusOK, map[string]int{"count": 3}),
+	)
+	server.Expect(httpclient.Post, "/reset").Respond(mockserver.Reset(), mockserver.Text(http.StatusOK, "recovered"))
+	server.Expect(httpclient.Get, "/down").Respond(mockserver.Status(http.StatusBadGateway)).Times(5)
+	server.Expect(httpclient.Post, "/lost").Respond(mockserver.Reset())
+
+	retryAll := fastPolicy()
+	retryAll.RetryNonIdempotent = true
+
+	tests := []struct {
+		testNum  int
+		method   string
+		path     string
+		policy   httpclient.RetryPolicy
+		expected string
+		attempts int
+	}{
+		{1, httpclient.Get, "flaky", nil, `{"count":3}`, 3},
+		// The transport resends idempotent requests itself when a reused connection is closed, use POST so the reset is
+		// seen by the retry policy.
+		{2, httpclient.Post, "reset", retryAll, "recovered", 2},
+		{3, httpclient.Get, "down", nil, "502 Bad Gateway", 5},
+		// The POST may have been processed so it is not retried by default.
+		{4, httpclient.Post, "lost", nil, "connection reset", 1},
+	}
+
+	for _, test := range tests {
+		b := client.NewRequest(context.Background(), test.method, test.path)
+		if test.policy != nil {
+			b.RetryPolicy(test.policy)
+		}
+
+		r, err := b.Do()
+
+		result := ""
+		if err != nil {
+			result = err.Error()
+		} else {
+			result = r.RespBody()
+		}
+
+		if !strings.Contains(result, test.expected) || r.Metadata().Attempts != test.attempts {
+			t.Errorf("\nTest: %d\nExpected: %s after %d attempts\nGot.....: %s after %d attempts",
+				test.testNum, test.expected, test.attempts, result, r.Metadata().Attempts)
+		}
+	}
+
+	server.Verify()
+}
+
+func TestMatching(t *testing.T) {
+	server := mockserver.New(t)
+	client := newClient(t, server.URL)
+
+	server.Expect(httpclient.Post, "/items").Header("X-Tenant", "a").JSONBody(`{"name":"x","tags":["b","c"]}`).
+		Respond(mockserver.JSON(http.StatusCreated, `{"id":1}`))
+	server.Expect(httpclient.Post, "/items").JSONBody(map[string]interface{}{"name": "y"}).
+		Respond(mockserver.JSON(http.StatusCreated, `{"id":2}`))
+	server.Expect("", "/any").Times(2)
+
+	tests := []struct {
+		testNum  int
+		builder  *httpclient.RequestBuilder
+		expected string
+	}{
+		{
+			1, client.Post(context.Background(), "items", map[string]interface{}{"tags": []string{"b", "c"}, "name": "x"}).
+				Header("X-Tenant", "a"), `{"id":1}`,
+		},
+		{2, client.Post(context.Background(), "items", map[string]string{"name": "y"}), `{"id":2}`},
+		{3, client.Post(context.Background(), "items", map[string]string{"name": "x"}), "501 Not Implemented"},
+		{4, client.Delete(context.Background(), "any"), ""},
+		{5, client.Put(context.Background(), "any", nil), ""},
+		{6, client.Get(context.Background(), "any"), "501 Not Implemented"},
+	}
+
+	for _, test := range tests {
+		r, err := test.builder.Do()
+
+		result := ""
+		if err != nil {
+			result = err.Error()
+		} else {
+			result = r.RespBody()
+		}
+
+		if !strings.Contains(result, test.expected) || (len(test.expected) == 0 && len(result) > 0) {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %s", test.testNum, test.expected, result)
+		}
+	}
+
+	unmet := server.Unmet()
+	expected := "[unexpected request POST /items unexpected request GET /any]"
+
+	if fmt.Sprint(unmet) != expected || len(server.Requests()) != 6 {
+		t.Errorf("\nExpected: %s\nGot.....: %v, %d requests", expected, unmet, len(server.Requests()))
+	}
+}
+
+func TestLatencyAndUnmet(t *testing.T) {
+	server := mockserver.New(t)
+	client := newClient(t, server.URL)
+
+	slow := server.Expect(httpclient.Get, "/slow").Respond(mockserver.Text(http.StatusOK, "late").After(200 * time.Millisecond))
+	server.Expect(httpclient.Get, "/never")
+	server.Expect(httpclient.Get, "/twice").Respond(mockserver.Status(http.StatusOK), mockserver.Status(http.StatusAccepted))
+
+	_, err := client.Get(context.Background(), "slow").Timeout(20 * time.Millisecond).RetryPolicy(nil).Do()
+	if !httpclient.IsRetryableError(err) || slow.Calls() != 1 {
+		t.Errorf("expected timeout, got %v after %d calls", err, slow.Calls())
+	}
+
+	r, err := client.Get(context.Background(), "twice").Do()
+	if err != nil || r.ResponseCode() != http.StatusOK {
+		t.Errorf("expected first response of sequence, got %v", err)
+	}
+
+	unmet := server.Unmet()
+	expected := "[expected GET /never (1 calls), got 0 calls expected GET /twice (2 calls), got 1 calls]"
+
+	if fmt.Sprint(unmet) != expected {
+		t.Errorf("\nExpected: %s\nGot.....: %v", expected, unmet)
+	}
+}
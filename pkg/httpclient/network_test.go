@@ -0,0 +1,313 @@
+package httpclient_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+)
+
+// hostServer responds with the name of the server and the Host header of the request.
+func hostServer(t *testing.T, name string, secure bool) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", name, r.Host)
+	})
+
+	server := httptest.NewUnstartedServer(handler)
+	if secure {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// tunnel connects to the address using the dial function and copies data between the connections until either
+// is closed.
+func tunnel(t *testing.T, conn net.Conn, dial func(ctx context.Context, network, addr string) (net.Conn, error), addr string) {
+	defer conn.Close()
+
+	target, err := dial(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Errorf("failed to connect to %s, %s", addr, err)
+
+		return
+	}
+
+	defer target.Close()
+
+	go io.Copy(target, conn) // nolint:errcheck // ok
+
+	io.Copy(conn, target) // nolint:errcheck // ok
+}
+
+// proxyServer is an HTTP proxy recording the last request it received, it connects to hosts using the overrides.
+func proxyServer(t *testing.T, hosts map[string]string) (*httptest.Server, *atomic.Value) {
+	last := &atomic.Value{}
+	last.Store("")
+
+	dial, err := httpclient.DialFunc(&httpclient.DialOptions{Hosts: hosts})
+	if err != nil {
+		t.Fatalf("failed to create dialer, %s", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last.Store(fmt.Sprintf("%s %s %s", r.Method, r.RequestURI, r.Header.Get("Proxy-Authorization")))
+
+		if r.Method != http.MethodConnect {
+			fmt.Fprintf(w, "proxy %s", r.Host)
+
+			return
+		}
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack connection, %s", err)
+
+			return
+		}
+
+		if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+			t.Errorf("failed to write response, %s", err)
+		}
+
+		tunnel(t, conn, dial, r.RequestURI)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, last
+}
+
+// socksServer is a SOCKS5 proxy supporting the CONNECT command without authentication, it records the last address
+// requested and connects to hosts using the overrides.
+func socksServer(t *testing.T, hosts map[string]string) (string, *atomic.Value) {
+	last := &atomic.Value{}
+	last.Store("")
+
+	dial, err := httpclient.DialFunc(&httpclient.DialOptions{Hosts: hosts})
+	if err != nil {
+		t.Fatalf("failed to create dialer, %s", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen, %s", err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				addr, err := socksHandshake(conn)
+				if err != nil {
+					t.Errorf("socks handshake failed, %s", err)
+					conn.Close()
+
+					return
+				}
+
+				last.Store(addr)
+				tunnel(t, conn, dial, addr)
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), last
+}
+
+// socksHandshake performs the server side of a SOCKS5 handshake, returning the address to connect to.
+func socksHandshake(conn net.Conn) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return "", err
+	}
+
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return "", err
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+
+	var host string
+
+	switch request[3] {
+	case 1:
+		ip := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+
+		host = net.IP(ip).String()
+	case 3:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+
+		host = string(name)
+	default:
+		return "", fmt.Errorf("unsupported address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+func TestProxy(t *testing.T) {
+	plain := hostServer(t, "plain", false)
+	secure := hostServer(t, "secure", true)
+	hosts := map[string]string{"example.com:443": secure.Listener.Addr().String(), "example.com": plain.Listener.Addr().String()}
+	proxy, proxied := proxyServer(t, hosts)
+	socks, socksProxied := socksServer(t, hosts)
+
+	tests := []struct {
+		testNum  int
+		proxy    *httpclient.ProxyOptions
+		url      string
+		expected string
+		proxied  string
+	}{
+		{1, &httpclient.ProxyOptions{URL: proxy.URL}, "http://example.com/path", "proxy example.com", "GET http://example.com/path "},
+		{2, &httpclient.ProxyOptions{URL: "http://user:secret@" + proxy.Listener.Addr().String()}, "http://example.com/",
+			"proxy example.com", "GET http://example.com/ Basic dXNlcjpzZWNyZXQ="},
+		{3, &httpclient.ProxyOptions{URL: proxy.URL}, "https://example.com/", "secure example.com", "CONNECT example.com:443 "},
+		{4, &httpclient.ProxyOptions{URL: proxy.URL, NoProxy: []string{"other.com", "example.com"}}, "http://example.com/",
+			"plain example.com", ""},
+		{5, &httpclient.ProxyOptions{URL: proxy.URL, NoProxy: []string{"*"}}, "https://example.com/", "secure example.com", ""},
+		{6, nil, "http://example.com/", "plain example.com", ""},
+		{7, &httpclient.ProxyOptions{URL: "socks5://" + socks}, "https://example.com/", "secure example.com", "example.com:443"},
+		{8, &httpclient.ProxyOptions{URL: "socks5://" + socks}, "http://example.com/", "plain example.com", "example.com:80"},
+	}
+
+	for _, test := range tests {
+		proxied.Store("")
+		socksProxied.Store("")
+
+		client, err := httpclient.NewClient(httpclient.WithTransport(secure.Client().Transport), httpclient.WithRetryPolicy(nil),
+			httpclient.WithProxy(test.proxy), httpclient.WithDialer(&httpclient.DialOptions{Hosts: hosts}))
+		if err != nil {
+			t.Fatalf("failed to create client, %s", err)
+		}
+
+		r, err := client.Get(context.Background(), test.url).Do()
+
+		got := proxied.Load().(string) + socksProxied.Load().(string)
+		if err != nil || r.RespBody() != test.expected || got != test.proxied {
+			t.Errorf("\nTest: %d\nExpected: %q via %q\nGot.....: %q via %q, %v", test.testNum, test.expected, test.proxied, r.RespBody(), got, err)
+		}
+	}
+}
+
+func TestDialer(t *testing.T) {
+	plain := hostServer(t, "plain", false)
+	other := hostServer(t, "other", false)
+	_, port, _ := net.SplitHostPort(other.Listener.Addr().String())
+
+	socket := filepath.Join(t.TempDir(), "daemon.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on unix socket, %s", err)
+	}
+
+	daemon := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "daemon %s %s", r.Host, r.URL.Path)
+	}))
+	daemon.Listener = listener
+	daemon.Start()
+	t.Cleanup(daemon.Close)
+
+	tests := []struct {
+		testNum  int
+		opts     *httpclient.DialOptions
+		url      string
+		expected string
+	}{
+		{1, &httpclient.DialOptions{UnixSocket: socket}, "http://docker/v1/info", "daemon docker /v1/info"},
+		{2, &httpclient.DialOptions{Hosts: map[string]string{"API.example.com": plain.Listener.Addr().String()}},
+			"http://api.example.com:8080/", "plain api.example.com:8080"},
+		{3, &httpclient.DialOptions{Hosts: map[string]string{"api.example.com": "127.0.0.1"}},
+			"http://api.example.com:" + port + "/", "other api.example.com:" + port},
+		{4, &httpclient.DialOptions{Hosts: map[string]string{
+			"api.example.com": plain.Listener.Addr().String(), "api.example.com:81": other.Listener.Addr().String(),
+		}}, "http://api.example.com:81/", "other api.example.com:81"},
+		{5, nil, plain.URL, "plain " + plain.Listener.Addr().String()},
+	}
+
+	for _, test := range tests {
+		client, err := httpclient.NewClient(httpclient.WithDialer(test.opts), httpclient.WithRetryPolicy(nil))
+		if err != nil {
+			t.Fatalf("failed to create client, %s", err)
+		}
+
+		r, err := client.Get(context.Background(), test.url).Do()
+		if err != nil || r.RespBody() != test.expected {
+			t.Errorf("\nTest: %d\nExpected: %q\nGot.....: %q, %v", test.testNum, test.expected, r.RespBody(), err)
+		}
+	}
+}
+
+func TestNetworkConfigErrors(t *testing.T) {
+	tests := []struct {
+		testNum int
+		options []httpclient.ClientOption
+	}{
+		{1, []httpclient.ClientOption{httpclient.WithProxy(&httpclient.ProxyOptions{URL: "ftp://proxy:21"})}},
+		{2, []httpclient.ClientOption{httpclient.WithProxy(&httpclient.ProxyOptions{URL: "http://"})}},
+		{3, []httpclient.ClientOption{httpclient.WithProxy(&httpclient.ProxyOptions{URL: "http://proxy:%zz"})}},
+		{4, []httpclient.ClientOption{httpclient.WithDialer(&httpclient.DialOptions{
+			UnixSocket: "/var/run/docker.sock", Hosts: map[string]string{"a": "b"},
+		})}},
+		{5, []httpclient.ClientOption{httpclient.WithDialer(&httpclient.DialOptions{DNSServer: "10.0.0.10", Resolver: &net.Resolver{}})}},
+		{6, []httpclient.ClientOption{
+			httpclient.WithTransport(http.NewFileTransport(http.Dir("."))),
+			httpclient.WithProxy(&httpclient.ProxyOptions{URL: "http://proxy:3128"}),
+		}},
+	}
+
+	for _, test := range tests {
+		if _, err := httpclient.NewClient(test.options...); !errors.Is(err, httpclient.ErrorNetworkConfig) {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %v", test.testNum, httpclient.ErrorNetworkConfig, err)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// DefaultMaxPages is the number of pages a Paginator fetches if PageOptions.MaxPages is not set.
+const DefaultMaxPages = 1000
+
+var ErrorMaxPages = errors.New("maximum number of pages fetched")
+
+type (
+	// PageScheme is the interface used to find the next page of a paginated API.
+	PageScheme interface {
+		// Next is called after each page is fetched with the page's URL, the completed request and the number of
+		// items on the page. It returns the URL of the next page or nil if there are no more pages.
+		Next(current *url.URL, r ReqResp, items int) (*url.URL, error)
+	}
+
+	// LinkHeader is a PageScheme following the "next" link in the Link header of each page, see RFC 8288.
+	LinkHeader struct{}
+
+	// ContinueToken is a PageScheme for Kubernetes style APIs, which return a token in metadata.continue that is
+	// passed in the continue query parameter to get the next page.
+	ContinueToken struct {
+		Param string // Query parameter the token is passed in, defaults to "continue".
+	}
+
+	// PageNumber is a PageScheme incrementing a page number query parameter until a page has fewer items than the
+	// page size or no items.
+	PageNumber struct {
+		Param     string // Page number query parameter, defaults to "page".
+		First     int    // Number of the first page, defaults to one or zero if ZeroBased is set.
+		ZeroBased bool   // Set if page numbers start at zero.
+		SizeParam string // Optional page size query parameter.
+		Size      int    // Page size, if zero paging stops at the first empty page.
+	}
+
+	// Offset is a PageScheme incrementing an offset query parameter by the number of items received until a page
+	// has fewer items than the limit or no items.
+	Offset struct {
+		Param      string // Offset query parameter, defaults to "offset".
+		LimitParam string // Optional limit query parameter.
+		Limit      int    // Page size, if zero paging stops at the first empty page.
+	}
+
+	// PageOptions holds the settings of a Paginator.
+	PageOptions[T any] struct {
+		Scheme PageScheme // Defaults to LinkHeader.
+		// Extract returns the items in a page, it defaults to decoding the response body as a JSON array.
+		Extract  func(r ReqResp) ([]T, error)
+		MaxPages int // Maximum number of pages fetched, defaults to DefaultMaxPages.
+	}
+
+	// Paginator iterates over the items of a paginated API, fetching pages as required.
+	//
+	//	for p.Next() {
+	//		item := p.Item()
+	//	}
+	//	if err := p.Err(); err != nil {
+	//	}
+	Paginator[T any] struct {
+		builder *RequestBuilder
+		opts    PageOptions[T]
+		next    *url.URL
+		items   []T
+		index   int
+		item    T
+		pages   int
+		err     error
+	}
+)
+
+// Paginate returns a Paginator fetching pages starting with the request built by b, further pages use the same
+// method, headers and settings with the URL given by the page scheme. Fetching stops if b's context is cancelled.
+func Paginate[T any](b *RequestBuilder, opts *PageOptions[T]) *Paginator[T] {
+	p := &Paginator[T]{builder: b}
+
+	if opts != nil {
+		p.opts = *opts
+	}
+
+	if p.opts.Scheme == nil {
+		p.opts.Scheme = LinkHeader{}
+	}
+
+	if p.opts.Extract == nil {
+		p.opts.Extract = DecodeJSON[[]T]
+	}
+
+	if p.opts.MaxPages <= 0 {
+		p.opts.MaxPages = DefaultMaxPages
+	}
+
+	p.next, p.err = b.URL()
+
+	return p
+}
+
+// Next advances to the next item, fetching the next page if required. It returns false when there are no more items
+// or an error occurs.
+func (p *Paginator[T]) Next() bool {
+	for p.index >= len(p.items) {
+		if p.err != nil || p.next == nil {
+			return false
+		}
+
+		p.err = p.fetch()
+	}
+
+	p.item = p.items[p.index]
+	p.index++
+
+	return true
+}
+
+// Item returns the current item.
+func (p *Paginator[T]) Item() T {
+	return p.item
+}
+
+// Err returns the error that stopped the iteration, nil if all the items were returned.
+func (p *Paginator[T]) Err() error {
+	return p.err
+}
+
+// Pages returns the number of pages fetched.
+func (p *Paginator[T]) Pages() int {
+	return p.pages
+}
+
+// All returns the remaining items.
+func (p *Paginator[T]) All() ([]T, error) {
+	items := []T{}
+
+	for p.Next() {
+		items = append(items, p.Item())
+	}
+
+	return items, p.Err()
+}
+
+// fetch fetches the next page.
+func (p *Paginator[T]) fetch() error {
+	if err := p.builder.ctx.Err(); err != nil {
+		return err
+	}
+
+	if p.pages >= p.opts.MaxPages {
+		return fmt.Errorf("%w: %d", ErrorMaxPages, p.pages)
+	}
+
+	b := *p.builder
+	b.path = p.next.String()
+	b.query = url.Values{}
+
+	r, err := b.Do()
+	if err != nil {
+		return err
+	}
+
+	p.pages++
+
+	items, err := p.opts.Extract(r)
+	if err != nil {
+		return err
+	}
+
+	next, err := p.opts.Scheme.Next(p.next, r, len(items))
+	if err != nil {
+		return err
+	}
+
+	p.items, p.index, p.next = items, 0, next
+
+	return nil
+}
+
+// ItemsField returns a PageOptions.Extract function decoding the items from a field of a JSON object response,
+// e.g. ItemsField[T]("items") for Kubernetes lists.
+func ItemsField[T any](name string) func(r ReqResp) ([]T, error) {
+	return func(r ReqResp) ([]T, error) {
+		page, err := DecodeJSON[map[string]json.RawMessage](r)
+		if err != nil {
+			return nil, err
+		}
+
+		var items []T
+
+		if data, ok := page[name]; ok {
+			if err := json.Unmarshal(data, &items); err != nil {
+				return nil, decodingResponseBodyError(err.Error())
+			}
+		}
+
+		return items, nil
+	}
+}
+
+// Next implements PageScheme.
+func (LinkHeader) Next(current *url.URL, r ReqResp, _ int) (*url.URL, error) {
+	link, ok := ParseLinks(r.RespHeader())["next"]
+	if !ok {
+		return nil, nil
+	}
+
+	next, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrorInvalidURL, err)
+	}
+
+	return current.ResolveReference(next), nil
+}
+
+// Next implements PageScheme.
+func (s ContinueToken) Next(current *url.URL, r ReqResp, _ int) (*url.URL, error) {
+	page, err := DecodeJSON[struct {
+		Metadata struct {
+			Continue string `json:"continue"`
+		} `json:"metadata"`
+	}](r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Metadata.Continue) == 0 {
+		return nil, nil
+	}
+
+	param := s.Param
+	if len(param) == 0 {
+		param = "continue"
+	}
+
+	return withQuery(current, param, page.Metadata.Continue), nil
+}
+
+// Next implements PageScheme.
+func (s PageNumber) Next(current *url.URL, _ ReqResp, items int) (*url.URL, error) {
+	if items == 0 || items < s.Size {
+		return nil, nil
+	}
+
+	param := s.Param
+	if len(param) == 0 {
+		param = "page"
+	}
+
+	page := s.First
+	if page == 0 && !s.ZeroBased {
+		page = one
+	}
+
+	if value := current.Query().Get(param); len(value) > 0 {
+		var err error
+		if page, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("%w: invalid page number %q", ErrorInvalidURL, value)
+		}
+	}
+
+	next := withQuery(current, param, strconv.Itoa(page+1))
+	if len(s.SizeParam) > 0 && s.Size > 0 {
+		next = withQuery(next, s.SizeParam, strconv.Itoa(s.Size))
+	}
+
+	return next, nil
+}
+
+// Next implements PageScheme.
+func (s Offset) Next(current *url.URL, _ ReqResp, items int) (*url.URL, error) {
+	if items == 0 || items < s.Limit {
+		return nil, nil
+	}
+
+	param := s.Param
+	if len(param) == 0 {
+		param = "offset"
+	}
+
+	offset := 0
+
+	if value := current.Query().Get(param); len(value) > 0 {
+		var err error
+		if offset, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("%w: invalid offset %q", ErrorInvalidURL, value)
+		}
+	}
+
+	next := withQuery(current, param, strconv.Itoa(offset+items))
+	if len(s.LimitParam) > 0 && s.Limit > 0 {
+		next = withQuery(next, s.LimitParam, strconv.Itoa(s.Limit))
+	}
+
+	return next, nil
+}
+
+// withQuery returns a copy of the URL with a query parameter set.
+func withQuery(u *url.URL, name, value string) *url.URL {
+	result := *u
+	query := result.Query()
+	query.Set(name, value)
+	result.RawQuery = query.Encode()
+
+	return &result
+}
@@ -0,0 +1,155 @@
+package httpclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+)
+
+// pageServer serves the values 1 to 5 paginated using each of the supported schemes.
+func pageServer(t *testing.T) *httptest.Server {
+	values := []int{1, 2, 3, 4, 5}
+	pageSize := 2
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		start := 0
+
+		switch r.URL.Path {
+		case "/link":
+			start, _ = strconv.Atoi(query.Get("start"))
+			if start+pageSize < len(values) {
+				w.Header().Set("Link", fmt.Sprintf(`</link?start=%d>; rel="next"`, start+pageSize))
+			}
+		case "/k8s":
+			start, _ = strconv.Atoi(query.Get("continue"))
+		case "/pages":
+			page, _ := strconv.Atoi(query.Get("page"))
+			if page > 0 {
+				start = (page - 1) * pageSize
+			}
+		case "/zero":
+			page, _ := strconv.Atoi(query.Get("page"))
+			start = page * pageSize
+		case "/offset":
+			start, _ = strconv.Atoi(query.Get("offset"))
+		default:
+			http.NotFound(w, r)
+
+			return
+		}
+
+		if start > len(values) {
+			start = len(values)
+		}
+
+		end := start + pageSize
+		if end > len(values) {
+			end = len(values)
+		}
+
+		var body interface{} = values[start:end]
+
+		if r.URL.Path == "/k8s" {
+			token := ""
+			if end < len(values) {
+				token = strconv.Itoa(end)
+			}
+
+			body = map[string]interface{}{"items": values[start:end], "metadata": map[string]string{"continue": token}}
+		}
+
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			t.Errorf("failed to encode page, %s", err)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestPaginate(t *testing.T) {
+	server := pageServer(t)
+
+	client, err := httpclient.NewClient(httpclient.WithBaseURL(server.URL), httpclient.WithRetryPolicy(nil))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	ctx := context.Background()
+
+	tests := []struct {
+		testNum  int
+		builder  *httpclient.RequestBuilder
+		opts     *httpclient.PageOptions[int]
+		expected string
+		pages    int
+		err      error
+	}{
+		{1, client.Get(ctx, "link"), nil, "[1 2 3 4 5]", 3, nil},
+		{2, client.Get(ctx, "k8s"), &httpclient.PageOptions[int]{
+			Scheme: httpclient.ContinueToken{}, Extract: httpclient.ItemsField[int]("items"),
+		}, "[1 2 3 4 5]", 3, nil},
+		{3, client.Get(ctx, "pages").Query("size", "2"), &httpclient.PageOptions[int]{
+			Scheme: httpclient.PageNumber{Size: 2},
+		}, "[1 2 3 4 5]", 3, nil},
+		{4, client.Get(ctx, "pages"), &httpclient.PageOptions[int]{Scheme: httpclient.PageNumber{}}, "[1 2 3 4 5]", 4, nil},
+		{5, client.Get(ctx, "offset"), &httpclient.PageOptions[int]{
+			Scheme: httpclient.Offset{LimitParam: "limit", Limit: 2},
+		}, "[1 2 3 4 5]", 3, nil},
+		{6, client.Get(ctx, "link"), &httpclient.PageOptions[int]{MaxPages: 2}, "[1 2 3 4]", 2, httpclient.ErrorMaxPages},
+		{7, client.Get(ctx, "missing/link"), nil, "[]", 0, httpclient.ErrorRequestFailed},
+		{8, client.Get(ctx, "k8s"), nil, "[]", 1, httpclient.ErrorDecodingRespBody},
+		{9, client.Get(ctx, "zero"), &httpclient.PageOptions[int]{
+			Scheme: httpclient.PageNumber{ZeroBased: true, Size: 2},
+		}, "[1 2 3 4 5]", 3, nil},
+		{10, client.Get(ctx, "zero").Query("page", "1"), &httpclient.PageOptions[int]{
+			Scheme: httpclient.PageNumber{ZeroBased: true, Size: 2},
+		}, "[3 4 5]", 2, nil},
+	}
+
+	for _, test := range tests {
+		p := httpclient.Paginate(test.builder, test.opts)
+
+		items, err := p.All()
+		if fmt.Sprint(items) != test.expected || p.Pages() != test.pages || !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+			t.Errorf("\nTest: %d\nExpected: %s, %d pages, %v\nGot.....: %v, %d pages, %v",
+				test.testNum, test.expected, test.pages, test.err, items, p.Pages(), err)
+		}
+	}
+}
+
+func TestPaginateCancel(t *testing.T) {
+	server := pageServer(t)
+
+	client, err := httpclient.NewClient(httpclient.WithRetryPolicy(nil))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := httpclient.Paginate[int](client.Get(ctx, server.URL+"/link"), nil)
+	items := []int{}
+
+	for p.Next() {
+		items = append(items, p.Item())
+
+		if len(items) == 3 {
+			cancel()
+		}
+	}
+
+	if fmt.Sprint(items) != "[1 2 3 4]" || !errors.Is(p.Err(), context.Canceled) || p.Pages() != 2 {
+		t.Errorf("expected iteration to stop at the page after cancellation, got %v, %d pages, %v", items, p.Pages(), p.Err())
+	}
+}
@@ -0,0 +1,172 @@
+// Package prometheus provides an httpclient.Metrics implementation recording Prometheus metrics. It is a separate
+// module so clients not using Prometheus do not depend on its client library.
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+)
+
+const one = 1
+
+var ErrorMetrics = errors.New("failed to register metrics")
+
+func metricsError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrorMetrics, msg)
+}
+
+type (
+	// Options holds the settings of Metrics.
+	Options struct {
+		Namespace string    // Prefix of the metric names, defaults to "httpclient".
+		Subsystem string    // Optional name added to the metric names after the namespace.
+		Buckets   []float64 // Latency histogram buckets in seconds, defaults to prometheus.DefBuckets.
+		// Registerer the metrics are registered with, defaults to prometheus.DefaultRegisterer. If the metrics are
+		// already registered the existing metrics are used, so clients can share them.
+		Registerer prom.Registerer
+	}
+
+	// Metrics is an httpclient.Metrics implementation recording Prometheus metrics labelled by host and method:
+	//
+	//	<namespace>_requests_total            requests completed by status class, "none" if no response was received
+	//	<namespace>_request_duration_seconds  request latency including retries
+	//	<namespace>_retries_total             retries made
+	//	<namespace>_reauths_total             requests resent with fresh credentials, these are not retries
+	//	<namespace>_attempts_total            attempts made by status class
+	//	<namespace>_attempt_duration_seconds  attempt latency
+	//	<namespace>_connections_total         attempts by whether an existing connection was reused
+	//	<namespace>_cache_total               requests served from the cache by cache status, "hit" or "revalidated"
+	Metrics struct {
+		requests        *prom.CounterVec
+		requestDuration *prom.HistogramVec
+		retries         *prom.CounterVec
+		reauths         *prom.CounterVec
+		attempts        *prom.CounterVec
+		attemptDuration *prom.HistogramVec
+		connections     *prom.CounterVec
+		cache           *prom.CounterVec
+	}
+)
+
+// NewMetrics returns a Metrics registered as given by the options, nil options use the defaults.
+func NewMetrics(opts *Options) (*Metrics, error) {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+
+	if len(o.Namespace) == 0 {
+		o.Namespace = "httpclient"
+	}
+
+	if len(o.Buckets) == 0 {
+		o.Buckets = prom.DefBuckets
+	}
+
+	if o.Registerer == nil {
+		o.Registerer = prom.DefaultRegisterer
+	}
+
+	counter := func(name, help string, labels ...string) (*prom.CounterVec, error) {
+		return register(o.Registerer, prom.NewCounterVec(prom.CounterOpts{
+			Namespace: o.Namespace, Subsystem: o.Subsystem, Name: name, Help: help,
+		}, append([]string{"host", "method"}, labels...)))
+	}
+
+	histogram := func(name, help string) (*prom.HistogramVec, error) {
+		return register(o.Registerer, prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: o.Namespace, Subsystem: o.Subsystem, Name: name, Help: help, Buckets: o.Buckets,
+		}, []string{"host", "method"}))
+	}
+
+	m := &Metrics{}
+
+	var err error
+
+	if m.requests, err = counter("requests_total", "Requests completed by status class.", "status_class"); err != nil {
+		return nil, err
+	}
+
+	if m.requestDuration, err = histogram("request_duration_seconds", "Request latency including retries."); err != nil {
+		return nil, err
+	}
+
+	if m.retries, err = counter("retries_total", "Retries made."); err != nil {
+		return nil, err
+	}
+
+	if m.reauths, err = counter("reauths_total", "Requests resent with fresh credentials."); err != nil {
+		return nil, err
+	}
+
+	if m.attempts, err = counter("attempts_total", "Attempts made by status class.", "status_class"); err != nil {
+		return nil, err
+	}
+
+	if m.attemptDuration, err = histogram("attempt_duration_seconds", "Attempt latency."); err != nil {
+		return nil, err
+	}
+
+	if m.connections, err = counter("connections_total", "Attempts by connection reuse.", "reused"); err != nil {
+		return nil, err
+	}
+
+	if m.cache, err = counter("cache_total", "Requests served from the cache by cache status.", "cache"); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// register registers the collector, returning the existing collector if an identical one is already registered.
+func register[T prom.Collector](registerer prom.Registerer, collector T) (T, error) {
+	if err := registerer.Register(collector); err != nil {
+		var registered prom.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			if existing, ok := registered.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+
+		return collector, metricsError(err.Error())
+	}
+
+	return collector, nil
+}
+
+// ObserveAttempt implements httpclient.Metrics.
+func (m *Metrics) ObserveAttempt(attempt httpclient.AttemptInfo) {
+	m.attempts.WithLabelValues(attempt.Host, attempt.Method, httpclient.StatusClass(attempt.StatusCode)).Inc()
+	m.attemptDuration.WithLabelValues(attempt.Host, attempt.Method).Observe(attempt.Duration.Seconds())
+
+	if attempt.Err == nil {
+		m.connections.WithLabelValues(attempt.Host, attempt.Method, strconv.FormatBool(attempt.ConnReused)).Inc()
+	}
+}
+
+// ObserveRequest implements httpclient.Metrics.
+func (m *Metrics) ObserveRequest(request httpclient.RequestInfo) {
+	m.requests.WithLabelValues(request.Host, request.Method, httpclient.StatusClass(request.StatusCode)).Inc()
+	m.requestDuration.WithLabelValues(request.Host, request.Method).Observe(request.Latency.Seconds())
+
+	retries := request.Attempts - one
+
+	if request.Reauth {
+		retries--
+
+		m.reauths.WithLabelValues(request.Host, request.Method).Inc()
+	}
+
+	if retries > 0 {
+		m.retries.WithLabelValues(request.Host, request.Method).Add(float64(retries))
+	}
+
+	if request.Cache != httpclient.CacheMiss {
+		m.cache.WithLabelValues(request.Host, request.Method, request.Cache.String()).Inc()
+	}
+}
@@ -0,0 +1,185 @@
+package prometheus_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/prometheus"
+)
+
+// metricsRequests sends requests to a server responding 503, 200 and then 404, one of which is served from the
+// cache, to a closed server and to a server requiring fresh credentials. It returns the hosts of the servers.
+func metricsRequests(t *testing.T, metrics httpclient.Metrics) (string, string, string) {
+	cached := func(status int) mockserver.Response {
+		response := mockserver.Status(status)
+		response.Header = http.Header{"Cache-Control": {"max-age=60"}}
+
+		return response
+	}
+
+	server := mockserver.New(t)
+	server.Expect("", "/").Respond(cached(http.StatusServiceUnavailable), cached(http.StatusOK), cached(http.StatusNotFound))
+	server.Expect(httpclient.Post, "/other").Respond(cached(http.StatusNotFound))
+
+	closed := mockserver.New(t)
+	closed.Close()
+
+	reauth := mockserver.New(t)
+	reauth.Expect(httpclient.Get, "/").Respond(mockserver.Status(http.StatusUnauthorized), mockserver.Status(http.StatusOK))
+
+	// A service account token is read again when it is rejected, so the request is resent with fresh credentials.
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("token"), 0o600); err != nil {
+		t.Fatalf("failed to write token, %s", err)
+	}
+
+	auth := httpclient.ServiceAccountToken(tokenFile)
+
+	policy := httpclient.NewBackoffPolicy()
+	policy.InitialInterval = time.Millisecond
+	policy.MaxInterval = 5 * time.Millisecond
+
+	client, err := httpclient.NewClient(httpclient.WithMetrics(metrics), httpclient.WithRetryPolicy(policy),
+		httpclient.WithCache(httpclient.NewCache(nil)))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	ctx := context.Background()
+
+	client.Get(ctx, server.URL).Do()                  // nolint:errcheck // ok
+	client.Get(ctx, server.URL).Do()                  // nolint:errcheck // ok
+	client.Delete(ctx, server.URL).Do()               // nolint:errcheck // ok
+	client.Get(ctx, closed.URL).RetryPolicy(nil).Do() // nolint:errcheck // ok
+	client.Post(ctx, server.URL+"/other", nil).Do()   // nolint:errcheck // ok
+	client.Get(ctx, reauth.URL).Auth(auth).Do()       // nolint:errcheck // ok
+
+	return serverHost(server), serverHost(closed), serverHost(reauth)
+}
+
+// serverHost returns the host of the server's URL.
+func serverHost(server *mockserver.Server) string {
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	registry := prom.NewRegistry()
+
+	metrics, err := prometheus.NewMetrics(&prometheus.Options{Registerer: registry})
+	if err != nil {
+		t.Fatalf("failed to create metrics, %s", err)
+	}
+
+	host, closed, reauth := metricsRequests(t, metrics)
+
+	expected := fmt.Sprintf(`
+# HELP httpclient_requests_total Requests completed by status class.
+# TYPE httpclient_requests_total counter
+httpclient_requests_total{host="%[1]s",method="DELETE",status_class="4xx"} 1
+httpclient_requests_total{host="%[1]s",method="GET",status_class="2xx"} 2
+httpclient_requests_total{host="%[1]s",method="POST",status_class="4xx"} 1
+httpclient_requests_total{host="%[2]s",method="GET",status_class="none"} 1
+httpclient_requests_total{host="%[3]s",method="GET",status_class="2xx"} 1
+# HELP httpclient_retries_total Retries made.
+# TYPE httpclient_retries_total counter
+httpclient_retries_total{host="%[1]s",method="GET"} 1
+# HELP httpclient_reauths_total Requests resent with fresh credentials.
+# TYPE httpclient_reauths_total counter
+httpclient_reauths_total{host="%[3]s",method="GET"} 1
+# HELP httpclient_attempts_total Attempts made by status class.
+# TYPE httpclient_attempts_total counter
+httpclient_attempts_total{host="%[1]s",method="DELETE",status_class="4xx"} 1
+httpclient_attempts_total{host="%[1]s",method="GET",status_class="2xx"} 1
+httpclient_attempts_total{host="%[1]s",method="GET",status_class="5xx"} 1
+httpclient_attempts_total{host="%[1]s",method="POST",status_class="4xx"} 1
+httpclient_attempts_total{host="%[2]s",method="GET",status_class="none"} 1
+httpclient_attempts_total{host="%[3]s",method="GET",status_class="2xx"} 1
+httpclient_attempts_total{host="%[3]s",method="GET",status_class="4xx"} 1
+# HELP httpclient_connections_total Attempts by connection reuse.
+# TYPE httpclient_connections_total counter
+httpclient_connections_total{host="%[1]s",method="DELETE",reused="true"} 1
+httpclient_connections_total{host="%[1]s",method="GET",reused="false"} 1
+httpclient_connections_total{host="%[1]s",method="GET",reused="true"} 1
+httpclient_connections_total{host="%[1]s",method="POST",reused="true"} 1
+httpclient_connections_total{host="%[3]s",method="GET",reused="false"} 1
+httpclient_connections_total{host="%[3]s",method="GET",reused="true"} 1
+# HELP httpclient_cache_total Requests served from the cache by cache status.
+# TYPE httpclient_cache_total counter
+httpclient_cache_total{cache="hit",host="%[1]s",method="GET"} 1
+`, host, closed, reauth)
+
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "httpclient_requests_total",
+		"httpclient_retries_total", "httpclient_reauths_total", "httpclient_attempts_total", "httpclient_connections_total", "httpclient_cache_total"); err != nil {
+		t.Errorf("unexpected metrics, %s", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics, %s", err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != "httpclient_request_duration_seconds" {
+			continue
+		}
+
+		count := uint64(0)
+		for _, metric := range family.GetMetric() {
+			count += metric.GetHistogram().GetSampleCount()
+		}
+
+		if count != 6 {
+			t.Errorf("expected 6 request latencies, got %d", count)
+		}
+	}
+}
+
+func TestPrometheusRegistration(t *testing.T) {
+	registry := prom.NewRegistry()
+
+	first, err := prometheus.NewMetrics(&prometheus.Options{Registerer: registry, Namespace: "api"})
+	if err != nil {
+		t.Fatalf("failed to create metrics, %s", err)
+	}
+
+	// Metrics created again with the same registry are shared.
+	second, err := prometheus.NewMetrics(&prometheus.Options{Registerer: registry, Namespace: "api"})
+	if err != nil {
+		t.Fatalf("failed to create metrics again, %s", err)
+	}
+
+	first.ObserveRequest(httpclient.RequestInfo{Host: "host", Method: "GET", StatusCode: 200, Attempts: 1})
+	second.ObserveRequest(httpclient.RequestInfo{Host: "host", Method: "GET", StatusCode: 201, Attempts: 3})
+
+	expected := `
+# HELP api_requests_total Requests completed by status class.
+# TYPE api_requests_total counter
+api_requests_total{host="host",method="GET",status_class="2xx"} 2
+# HELP api_retries_total Retries made.
+# TYPE api_retries_total counter
+api_retries_total{host="host",method="GET"} 2
+`
+
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "api_requests_total", "api_retries_total"); err != nil {
+		t.Errorf("expected metrics to be shared, %s", err)
+	}
+
+	conflicting := prom.NewCounter(prom.CounterOpts{Namespace: "other", Name: "retries_total", Help: "Conflict."})
+	registry.MustRegister(conflicting)
+
+	if _, err := prometheus.NewMetrics(&prometheus.Options{Registerer: registry, Namespace: "other"}); !errors.Is(err, prometheus.ErrorMetrics) {
+		t.Errorf("expected registration error, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package httpclient
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	adaptiveDecrease = 0.5
+	adaptiveIncrease = 0.1
+)
+
+type (
+	// RateLimiterOptions holds the settings of a RateLimiter.
+	RateLimiterOptions struct {
+		Rate      float64 // Requests per second across all hosts, zero for no limit.
+		Burst     int     // Requests that can be sent at once across all hosts, defaults to one.
+		HostRate  float64 // Requests per second to each host, zero for no limit.
+		HostBurst int     // Requests that can be sent at once to each host, defaults to one.
+		// Adaptive halves a host's rate when a 429 response is received, down to MinRate, restoring it gradually as
+		// other responses are received. Requests to the host are also paused for the time given in a Retry-After
+		// header, this is done even if HostRate is not set.
+		Adaptive bool
+		MinRate  float64 // Lowest rate adaptive slowdown reduces a host's rate to, defaults to a tenth of HostRate.
+	}
+
+	// RateLimiter is a token bucket rate limiter for requests, limiting the overall rate and the rate to each host.
+	// It is safe for concurrent use and may be shared by clients.
+	RateLimiter struct {
+		opts   RateLimiterOptions
+		mutex  sync.Mutex
+		global *tokenBucket
+		hosts  map[string]*tokenBucket
+	}
+
+	// tokenBucket holds up to burst tokens, refilled at rate per second, a rate of zero is unlimited.
+	tokenBucket struct {
+		rate   float64
+		burst  float64
+		tokens float64
+		last   time.Time
+		until  time.Time // Time before which no requests are allowed.
+	}
+)
+
+// NewRateLimiter returns a RateLimiter, nil options give a limiter that does not limit requests.
+func NewRateLimiter(opts *RateLimiterOptions) *RateLimiter {
+	l := &RateLimiter{hosts: map[string]*tokenBucket{}}
+
+	if opts != nil {
+		l.opts = *opts
+	}
+
+	if l.opts.MinRate <= 0 {
+		l.opts.MinRate = l.opts.HostRate / ten
+	}
+
+	l.global = newTokenBucket(l.opts.Rate, l.opts.Burst)
+
+	return l
+}
+
+// newTokenBucket returns a full bucket.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = one
+	}
+
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait waits until a request to the host is allowed or the context is done.
+func (l *RateLimiter) Wait(ctx context.Context, host string) error {
+	l.mutex.Lock()
+	now := time.Now()
+	hostBucket := l.host(host)
+	delay := time.Duration(math.Max(float64(l.global.reserve(now)), float64(hostBucket.reserve(now))))
+	l.mutex.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	if err := sleepContext(ctx, delay); err != nil {
+		l.mutex.Lock()
+		l.global.cancel()
+		hostBucket.cancel()
+		l.mutex.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// HostRate returns the current rate limit for the host, zero if it is not limited.
+func (l *RateLimiter) HostRate(host string) float64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.host(host).rate
+}
+
+// host returns the host's bucket, the mutex must be held.
+func (l *RateLimiter) host(host string) *tokenBucket {
+	b, ok := l.hosts[host]
+	if !ok {
+		b = newTokenBucket(l.opts.HostRate, l.opts.HostBurst)
+		l.hosts[host] = b
+	}
+
+	return b
+}
+
+// observe adapts the host's rate to a response if adaptive slowdown is enabled.
+func (l *RateLimiter) observe(host string, resp *http.Response) {
+	if !l.opts.Adaptive || resp == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b := l.host(host)
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		if b.rate > 0 && b.rate < l.opts.HostRate {
+			b.rate = math.Min(l.opts.HostRate, b.rate+l.opts.HostRate*adaptiveIncrease)
+		}
+
+		return
+	}
+
+	now := time.Now()
+	b.refill(now)
+
+	if b.rate > 0 {
+		b.rate = math.Max(l.opts.MinRate, b.rate*adaptiveDecrease)
+	}
+
+	if after, ok := RetryAfter(resp, now); ok && now.Add(after).After(b.until) {
+		b.until = now.Add(after)
+	}
+}
+
+// refill adds the tokens accumulated since the last refill.
+func (b *tokenBucket) refill(now time.Time) {
+	if b.rate > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+
+	b.last = now
+}
+
+// reserve takes a token, returning the time until it is available.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	var delay time.Duration
+
+	if b.rate > 0 {
+		b.refill(now)
+		b.tokens--
+
+		if b.tokens < 0 {
+			delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+		}
+	}
+
+	if pause := b.until.Sub(now); pause > delay {
+		delay = pause
+	}
+
+	return delay
+}
+
+// cancel returns a reserved token that was not used.
+func (b *tokenBucket) cancel() {
+	if b.rate > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+1)
+	}
+}
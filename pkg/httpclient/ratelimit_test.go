@@ -0,0 +1,141 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+)
+
+// timeRequests sends the requests concurrently, returning the time taken.
+func timeRequests(t *testing.T, limiter *httpclient.RateLimiter, urls ...string) time.Duration {
+	client, err := httpclient.NewClient(httpclient.WithRateLimiter(limiter), httpclient.WithRetryPolicy(nil))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+
+	for _, u := range urls {
+		wg.Add(1)
+
+		go func(u string) {
+			defer wg.Done()
+
+			if _, err := client.Get(context.Background(), u).Do(); err != nil {
+				t.Errorf("request failed, %s", err)
+			}
+		}(u)
+	}
+
+	wg.Wait()
+
+	return time.Since(start)
+}
+
+func TestRateLimiter(t *testing.T) {
+	a, b := mockserver.New(t), mockserver.New(t)
+	a.Expect(httpclient.Get, "/")
+	b.Expect(httpclient.Get, "/")
+
+	tests := []struct {
+		testNum int
+		opts    *httpclient.RateLimiterOptions
+		urls    []string
+		min     time.Duration
+		max     time.Duration
+	}{
+		{1, nil, []string{a.URL, a.URL, a.URL, b.URL}, 0, 50 * time.Millisecond},
+		{2, &httpclient.RateLimiterOptions{Rate: 50}, []string{a.URL, a.URL, b.URL, b.URL, b.URL}, 75 * time.Millisecond, 200 * time.Millisecond},
+		{3, &httpclient.RateLimiterOptions{Rate: 50, Burst: 5}, []string{a.URL, a.URL, b.URL, b.URL, b.URL}, 0, 50 * time.Millisecond},
+		{4, &httpclient.RateLimiterOptions{HostRate: 50}, []string{a.URL, a.URL, a.URL, a.URL, b.URL}, 55 * time.Millisecond, 180 * time.Millisecond},
+		{5, &httpclient.RateLimiterOptions{HostRate: 50}, []string{a.URL, a.URL, b.URL, b.URL}, 15 * time.Millisecond, 50 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		if elapsed := timeRequests(t, httpclient.NewRateLimiter(test.opts), test.urls...); elapsed < test.min || elapsed > test.max {
+			t.Errorf("\nTest: %d\nExpected: %s to %s\nGot.....: %s", test.testNum, test.min, test.max, elapsed)
+		}
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := httpclient.NewRateLimiter(&httpclient.RateLimiterOptions{Rate: 1})
+
+	if err := limiter.Wait(context.Background(), "host"); err != nil {
+		t.Fatalf("expected first request to be allowed, got %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		start := time.Now()
+		err := limiter.Wait(ctx, "host")
+
+		cancel()
+
+		// Cancelled waits return their token so the wait does not grow.
+		if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 100*time.Millisecond {
+			t.Errorf("expected wait to stop at deadline, got %v after %s", err, time.Since(start))
+		}
+	}
+}
+
+func TestAdaptiveRateLimit(t *testing.T) {
+	server := mockserver.New(t)
+	server.Expect(httpclient.Get, "/").Respond(statusResponses(http.Header{"Retry-After": {"0"}},
+		http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK, http.StatusOK, http.StatusTooManyRequests)...)
+
+	paused := mockserver.New(t)
+	paused.Expect(httpclient.Get, "/").Respond(statusResponses(http.Header{"Retry-After": {"1"}}, http.StatusTooManyRequests)...)
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse url, %s", err)
+	}
+
+	limiter := httpclient.NewRateLimiter(&httpclient.RateLimiterOptions{HostRate: 1000, HostBurst: 10, Adaptive: true, MinRate: 200})
+
+	client, err := httpclient.NewClient(httpclient.WithRateLimiter(limiter), httpclient.WithRetryPolicy(nil))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	rates := []string{}
+
+	for i := 0; i < 5; i++ {
+		client.Get(context.Background(), server.URL).Do() // nolint:errcheck // ok
+		rates = append(rates, fmt.Sprint(math.Round(limiter.HostRate(u.Host))))
+	}
+
+	if expected := "[500 250 350 450 225]"; fmt.Sprint(rates) != expected {
+		t.Errorf("\nExpected: %s\nGot.....: %v", expected, rates)
+	}
+
+	client.Get(context.Background(), paused.URL).Do() // nolint:errcheck // ok
+
+	pausedURL, err := url.Parse(paused.URL)
+	if err != nil {
+		t.Fatalf("failed to parse url, %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := limiter.Wait(ctx, pausedURL.Host); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected requests to be paused after Retry-After, got %v", err)
+	}
+
+	if err := limiter.Wait(ctx, u.Host); err != nil {
+		t.Errorf("expected other hosts not to be paused, got %v", err)
+	}
+}
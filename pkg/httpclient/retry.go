@@ -0,0 +1,261 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultMultiplier  = 2.0
+	defaultJitter      = 0.2
+	// idempotencyKeyHeader marks a request as safe to repeat, as for http.Transport.
+	idempotencyKeyHeader = "Idempotency-Key"
+)
+
+// RetryPolicy is the interface used to decide whether a request should be retried.
+type RetryPolicy interface {
+	// Retry is called after each attempt with the number of attempts made, the time elapsed since the first attempt
+	// and the response or error. It returns true and the delay before the next attempt if the request should be retried.
+	// If no response was received the error is an *AttemptError giving the request sent.
+	Retry(attempt int, elapsed time.Duration, resp *http.Response, err error) (time.Duration, bool)
+}
+
+// AttemptError is the error passed to a RetryPolicy when an attempt fails without a response.
+type AttemptError struct {
+	Request *http.Request // Request sent.
+	Err     error         // Error returned sending the request.
+}
+
+// Error implements the error interface.
+func (e *AttemptError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error returned sending the request.
+func (e *AttemptError) Unwrap() error {
+	return e.Err
+}
+
+// BackoffPolicy is a RetryPolicy using exponential backoff with jitter.
+type BackoffPolicy struct {
+	MaxAttempts      int           // Maximum number of attempts, including the first, zero or less for no limit.
+	InitialInterval  time.Duration // Delay before the first retry.
+	MaxInterval      time.Duration // Maximum delay between attempts.
+	Multiplier       float64       // Factor the delay is multiplied by after each attempt.
+	Jitter           float64       // Fraction of the delay randomly added or subtracted, e.g. 0.2 for +/-20%.
+	MaxElapsedTime   time.Duration // Time after which no more attempts are made, zero for no limit.
+	RetryStatusCodes []int         // Response status codes that are retried.
+	IgnoreRetryAfter bool          // Set to use the backoff delay rather than a response's Retry-After header.
+	MaxRetryAfter    time.Duration // Maximum delay taken from a Retry-After header, zero to use MaxInterval.
+	// RetryNonIdempotent retries RetryStatusCodes and errors for all methods. By default they are only retried for
+	// idempotent methods and requests with an Idempotency-Key header, as a POST or PATCH may have been processed.
+	// Failures to connect are retried for all methods.
+	RetryNonIdempotent bool
+	// RetryableError classifies errors as retryable, leave unset to use IsRetryableError.
+	RetryableError func(error) bool
+}
+
+// NewBackoffPolicy returns a BackoffPolicy with default settings, five attempts within a minute starting at a one
+// second delay and doubling up to ten seconds, retrying connection errors and 429, 502, 503 and 504 responses to
+// idempotent requests.
+func NewBackoffPolicy() *BackoffPolicy {
+	return &BackoffPolicy{
+		MaxAttempts:     defaultMaxAttempts,
+		InitialInterval: time.Second,
+		MaxInterval:     ten * time.Second,
+		Multiplier:      defaultMultiplier,
+		Jitter:          defaultJitter,
+		MaxElapsedTime:  time.Minute,
+		RetryStatusCodes: []int{
+			http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// NoRetryPolicy returns a RetryPolicy that never retries.
+func NoRetryPolicy() RetryPolicy {
+	return &BackoffPolicy{MaxAttempts: one}
+}
+
+// Retry implements RetryPolicy.
+func (p *BackoffPolicy) Retry(attempt int, elapsed time.Duration, resp *http.Response, err error) (time.Duration, bool) {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	if !p.retryable(resp, err) {
+		return 0, false
+	}
+
+	delay := p.Backoff(attempt)
+
+	if resp != nil && !p.IgnoreRetryAfter {
+		if after, ok := RetryAfter(resp, time.Now()); ok {
+			delay = p.capRetryAfter(after)
+		}
+	}
+
+	if p.MaxElapsedTime > 0 && elapsed+delay > p.MaxElapsedTime {
+		return 0, false
+	}
+
+	return delay, true
+}
+
+// Backoff returns the delay after an attempt, before jitter is applied it is InitialInterval * Multiplier^(attempt-1)
+// capped at MaxInterval.
+func (p *BackoffPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialInterval) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxInterval > 0 && delay > float64(p.MaxInterval) {
+		delay = float64(p.MaxInterval)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1) // nolint:gosec // ok
+	}
+
+	return time.Duration(delay)
+}
+
+// capRetryAfter limits a Retry-After delay to MaxRetryAfter, or MaxInterval if that is not set.
+func (p *BackoffPolicy) capRetryAfter(delay time.Duration) time.Duration {
+	limit := p.MaxRetryAfter
+	if limit <= 0 {
+		limit = p.MaxInterval
+	}
+
+	if limit > 0 && delay > limit {
+		return limit
+	}
+
+	return delay
+}
+
+// retryable returns true if the response or error should be retried. Errors other than failures to connect are
+// only retried for idempotent requests, as the request may have been processed.
+func (p *BackoffPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		retry := IsRetryableError(err)
+		if p.RetryableError != nil {
+			retry = p.RetryableError(err)
+		}
+
+		if !retry || p.RetryNonIdempotent || notSent(err) {
+			return retry
+		}
+
+		var attemptErr *AttemptError
+
+		return !errors.As(err, &attemptErr) || idempotent(attemptErr.Request)
+	}
+
+	if resp == nil || (!p.RetryNonIdempotent && !idempotent(resp.Request)) {
+		return false
+	}
+
+	for _, code := range p.RetryStatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// idempotent returns true if the request can be repeated without further side effects, i.e. its method is
+// idempotent or it has an idempotency key. A missing request is assumed to be idempotent.
+func idempotent(req *http.Request) bool {
+	if req == nil {
+		return true
+	}
+
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return len(req.Header.Get(idempotencyKeyHeader)) > 0
+}
+
+// IsRetryableError returns true if an error returned when sending a request is transient, i.e. timeouts,
+// refused or reset connections and connections closed before the response was received.
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var opErr *net.OpError
+
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// notSent returns true if the error shows the request was not sent because a connection could not be made.
+func notSent(err error) bool {
+	var opErr *net.OpError
+
+	return errors.Is(err, syscall.ECONNREFUSED) || (errors.As(err, &opErr) && opErr.Op == "dial")
+}
+
+// RetryAfter returns the delay specified by a response's Retry-After header, either in seconds or as an HTTP date.
+func RetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if len(value) == 0 {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+
+	return 0, true
+}
+
+// sleepContext waits for the delay or until the context is done.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
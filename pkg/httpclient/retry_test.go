@@ -0,0 +1,287 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+)
+
+func fastPolicy() *httpclient.BackoffPolicy {
+	policy := httpclient.NewBackoffPolicy()
+	policy.InitialInterval = time.Millisecond
+	policy.MaxInterval = 5 * time.Millisecond
+
+	return policy
+}
+
+// statusResponses returns responses with the status codes in turn, each with the header and a body giving its call
+// number.
+func statusResponses(header http.Header, codes ...int) []mockserver.Response {
+	responses := make([]mockserver.Response, len(codes))
+
+	for i, code := range codes {
+		responses[i] = mockserver.Text(code, fmt.Sprintf("call %d", i+1))
+		for name, values := range header {
+			responses[i].Header[name] = values
+		}
+	}
+
+	return responses
+}
+
+func newGet(t *testing.T, rawURL string) httpclient.ReqResp {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("failed to parse url, %s", err)
+	}
+
+	r, err := httpclient.NewReqResp(context.Background(), u, nil, nil, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create request, %s", err)
+	}
+
+	return r
+}
+
+func TestHTTPreqRetry(t *testing.T) {
+	limited := fastPolicy()
+	limited.MaxAttempts = 2
+
+	elapsed := fastPolicy()
+	elapsed.MaxAttempts = 0
+	elapsed.MaxElapsedTime = time.Millisecond
+
+	tests := []struct {
+		testNum  int
+		codes    []int
+		header   http.Header
+		policy   httpclient.RetryPolicy
+		calls    int
+		failed   bool
+		contains string
+	}{
+		{1, []int{503, 503, 200}, nil, fastPolicy(), 3, false, "call 3"},
+		{2, []int{429, 502, 504, 200}, http.Header{"Retry-After": []string{"0"}}, fastPolicy(), 4, false, "call 4"},
+		{3, []int{503}, nil, limited, 2, true, "503"},
+		{4, []int{500}, nil, fastPolicy(), 1, true, "500"},
+		{5, []int{503, 200}, nil, nil, 1, true, "503"},
+		{6, []int{503, 200}, http.Header{"Retry-After": []string{"60"}}, elapsed, 1, true, "503"},
+	}
+
+	for _, test := range tests {
+		server := mockserver.New(t)
+		expectation := server.Expect(httpclient.Get, "/").Respond(statusResponses(test.header, test.codes...)...)
+
+		r := newGet(t, server.URL)
+		r.SetRetryPolicy(test.policy)
+
+		err := r.HTTPreq()
+		if calls := expectation.Calls(); (err != nil) != test.failed || calls != test.calls {
+			t.Errorf("\nTest: %d\nExpected: calls %d, failed %t\nGot.....: calls %d, %v", test.testNum, test.calls, test.failed, calls, err)
+
+			continue
+		}
+
+		if err != nil && !errors.Is(err, httpclient.ErrorRequestFailed) {
+			t.Errorf("\nTest: %d\nExpected: ErrorRequestFailed\nGot.....: %s", test.testNum, err)
+		}
+
+		if err == nil && r.RespBody() != test.contains {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %s", test.testNum, test.contains, r.RespBody())
+		}
+	}
+}
+
+func TestHTTPreqRetryConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen, %s", err)
+	}
+
+	addr := ln.Addr().String()
+	ln.Close()
+
+	r := newGet(t, "http://"+addr)
+	r.SetRetryPolicy(fastPolicy())
+
+	if err := r.HTTPreq(); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("\nExpected: connection refused\nGot.....: %v", err)
+	}
+}
+
+func TestHTTPreqRetryTimeout(t *testing.T) {
+	policy := fastPolicy()
+	policy.MaxAttempts = 2
+
+	nonIdempotent := *policy
+	nonIdempotent.RetryNonIdempotent = true
+
+	tests := []struct {
+		testNum int
+		method  string
+		header  string
+		policy  *httpclient.BackoffPolicy
+		calls   int
+	}{
+		{1, http.MethodPost, "", policy, 1},
+		{2, http.MethodPatch, "", policy, 1},
+		{3, http.MethodPost, "key", policy, 2},
+		{4, http.MethodGet, "", policy, 2},
+		{5, http.MethodPost, "", &nonIdempotent, 2},
+	}
+
+	for _, test := range tests {
+		server := mockserver.New(t)
+		expectation := server.Expect(test.method, "/").Respond(mockserver.Status(http.StatusOK).After(time.Second))
+
+		client, err := httpclient.NewClient(httpclient.WithTimeout(20*time.Millisecond), httpclient.WithRetryPolicy(test.policy))
+		if err != nil {
+			t.Fatalf("failed to create client, %s", err)
+		}
+
+		b := client.NewRequest(context.Background(), test.method, server.URL)
+		if len(test.header) > 0 {
+			b.Header("Idempotency-Key", test.header)
+		}
+
+		if _, err := b.Do(); err == nil || expectation.Calls() != test.calls {
+			t.Errorf("\nTest: %d\nExpected: %d calls, timeout\nGot.....: %d calls, %v", test.testNum, test.calls, expectation.Calls(), err)
+		}
+	}
+
+	// A request that could not be sent is retried whatever its method.
+	refused := &httpclient.AttemptError{
+		Request: httptest.NewRequest(http.MethodPost, "http://example.com", nil),
+		Err:     &url.Error{Op: "Post", URL: "http://example.com", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}},
+	}
+
+	if _, retry := policy.Retry(1, 0, nil, refused); !retry {
+		t.Errorf("expected a refused connection to be retried for a POST")
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		testNum  int
+		err      error
+		expected bool
+	}{
+		{1, nil, false},
+		{2, context.Canceled, false},
+		{3, &url.Error{Op: "Get", URL: "http://x", Err: io.ErrUnexpectedEOF}, true},
+		{4, &url.Error{Op: "Get", URL: "http://x", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, true},
+		{5, &url.Error{Op: "Get", URL: "http://x", Err: syscall.ECONNRESET}, true},
+		{6, &url.Error{Op: "Get", URL: "http://x", Err: context.DeadlineExceeded}, true},
+		{7, errors.New("unsupported protocol scheme"), false},
+	}
+
+	for _, test := range tests {
+		if result := httpclient.IsRetryableError(test.err); result != test.expected {
+			t.Errorf("\nTest: %d, %v\nExpected: %t\nGot.....: %t", test.testNum, test.err, test.expected, result)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		testNum  int
+		value    string
+		expected time.Duration
+		found    bool
+	}{
+		{1, "", 0, false},
+		{2, "120", 2 * time.Minute, true},
+		{3, "-1", 0, false},
+		{4, now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{5, now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{6, "soon", 0, false},
+	}
+
+	for _, test := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if len(test.value) > 0 {
+			resp.Header.Set("Retry-After", test.value)
+		}
+
+		result, found := httpclient.RetryAfter(resp, now)
+		if result != test.expected || found != test.found {
+			t.Errorf("\nTest: %d\nExpected: %s, %t\nGot.....: %s, %t", test.testNum, test.expected, test.found, result, found)
+		}
+	}
+}
+
+func TestBackoffPolicyRetry(t *testing.T) {
+	policy := &httpclient.BackoffPolicy{
+		InitialInterval: time.Second, MaxInterval: 10 * time.Second, RetryStatusCodes: []int{http.StatusServiceUnavailable},
+	}
+
+	capped := *policy
+	capped.MaxRetryAfter = time.Minute
+
+	nonIdempotent := *policy
+	nonIdempotent.RetryNonIdempotent = true
+
+	tests := []struct {
+		testNum  int
+		policy   *httpclient.BackoffPolicy
+		method   string
+		header   http.Header
+		expected time.Duration
+		retry    bool
+	}{
+		{1, policy, http.MethodGet, nil, time.Second, true},
+		{2, policy, http.MethodGet, http.Header{"Retry-After": {"5"}}, 5 * time.Second, true},
+		{3, policy, http.MethodGet, http.Header{"Retry-After": {"86400"}}, 10 * time.Second, true},
+		{4, &capped, http.MethodGet, http.Header{"Retry-After": {"86400"}}, time.Minute, true},
+		{5, policy, http.MethodPut, nil, time.Second, true},
+		{6, policy, http.MethodPost, nil, 0, false},
+		{7, policy, http.MethodPatch, nil, 0, false},
+		{8, policy, http.MethodPost, http.Header{"Idempotency-Key": {"key"}}, time.Second, true},
+		{9, &nonIdempotent, http.MethodPost, nil, time.Second, true},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "http://example.com", nil)
+		for name, values := range test.header {
+			req.Header[name] = values
+		}
+
+		resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: test.header, Request: req}
+
+		delay, retry := test.policy.Retry(1, 0, resp, nil)
+		if delay != test.expected || retry != test.retry {
+			t.Errorf("\nTest: %d\nExpected: %s, %t\nGot.....: %s, %t", test.testNum, test.expected, test.retry, delay, retry)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := &httpclient.BackoffPolicy{InitialInterval: time.Second, MaxInterval: 10 * time.Second, Multiplier: 2}
+
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second} {
+		if result := policy.Backoff(attempt + 1); result != expected {
+			t.Errorf("\nAttempt: %d\nExpected: %s\nGot.....: %s", attempt+1, expected, result)
+		}
+	}
+
+	policy.Jitter = 0.5
+
+	for i := 0; i < 100; i++ {
+		if result := policy.Backoff(1); result < time.Second/2 || result > 3*time.Second/2 {
+			t.Fatalf("backoff with jitter out of range: %s", result)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// StatusCheck is a function returning true if a response status code is accepted as success.
+type StatusCheck func(statusCode int) bool
+
+// StatusError is returned when a request receives a response whose status is not accepted.
+// It wraps ErrorRequestFailed.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       string
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	return requestError(fmt.Sprintf("failed: %s %s", e.Status, e.Body)).Error()
+}
+
+// Unwrap returns ErrorRequestFailed.
+func (e *StatusError) Unwrap() error {
+	return ErrorRequestFailed
+}
+
+// Is2xx returns true for 2xx status codes, it is the default StatusCheck.
+func Is2xx(statusCode int) bool {
+	return statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices
+}
+
+// SuccessStatus returns a StatusCheck accepting only the given status codes.
+func SuccessStatus(codes ...int) StatusCheck {
+	return func(statusCode int) bool {
+		for _, code := range codes {
+			if statusCode == code {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// newStatusError returns a StatusError for a response.
+func newStatusError(resp *http.Response, body string) *StatusError {
+	return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header.Clone(), Body: body}
+}
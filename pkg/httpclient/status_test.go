@@ -0,0 +1,58 @@
+package httpclient_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+)
+
+func TestStatusCheck(t *testing.T) {
+	tests := []struct {
+		testNum int
+		code    int
+		check   httpclient.StatusCheck
+		failed  bool
+	}{
+		{1, http.StatusOK, nil, false},
+		{2, http.StatusAccepted, nil, false},
+		{3, http.StatusNoContent, nil, false},
+		{4, http.StatusMultipleChoices, nil, true},
+		{5, http.StatusNotFound, nil, true},
+		{6, http.StatusNotFound, httpclient.SuccessStatus(http.StatusOK, http.StatusNotFound), false},
+		{7, http.StatusAccepted, httpclient.SuccessStatus(http.StatusOK), true},
+		{8, http.StatusConflict, func(code int) bool { return code < http.StatusInternalServerError }, false},
+	}
+
+	for _, test := range tests {
+		server := mockserver.New(t)
+		server.Expect(httpclient.Get, "/").Respond(statusResponses(http.Header{"X-Request-Id": []string{"abc"}}, test.code)...)
+
+		r := newGet(t, server.URL)
+		r.SetStatusCheck(test.check)
+
+		err := r.HTTPreq()
+		if (err != nil) != test.failed {
+			t.Errorf("\nTest: %d\nExpected failure: %t\nGot.....: %v", test.testNum, test.failed, err)
+
+			continue
+		}
+
+		if err == nil {
+			continue
+		}
+
+		var statusErr *httpclient.StatusError
+		if !errors.As(err, &statusErr) || !errors.Is(err, httpclient.ErrorRequestFailed) {
+			t.Errorf("\nTest: %d\nExpected: StatusError\nGot.....: %v", test.testNum, err)
+
+			continue
+		}
+
+		if statusErr.StatusCode != test.code || statusErr.Header.Get("X-Request-Id") != "abc" || statusErr.Body != "call 1" {
+			t.Errorf("\nTest: %d\nUnexpected StatusError: %+v", test.testNum, statusErr)
+		}
+	}
+}
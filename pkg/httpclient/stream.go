@@ -0,0 +1,378 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	fileMode      = 0o644
+	sixtyFour     = 64
+	partialSuffix = ".partial"
+)
+
+var (
+	ErrorSizeLimit        = errors.New("response body exceeds size limit")
+	ErrorChecksumMismatch = errors.New("response body checksum mismatch")
+)
+
+func sizeLimitError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrorSizeLimit, msg)
+}
+
+func checksumError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrorChecksumMismatch, msg)
+}
+
+type (
+	// ProgressFunc is called as a body is transferred with the number of bytes transferred so far and the total size,
+	// -1 if the size is not known.
+	ProgressFunc func(transferred, total int64)
+
+	// Upload is a request body streamed from a reader rather than held in memory. Open is called for each attempt so
+	// the body can be resent if the request is retried.
+	Upload struct {
+		Open        func() (io.ReadCloser, error)
+		ContentType string       // Content type, defaults to application/octet-stream.
+		Size        int64        // Size of the body, zero or less if not known in which case it is sent chunked.
+		Progress    ProgressFunc // Called as the body is sent.
+	}
+
+	// DownloadOptions controls how a response body is streamed.
+	DownloadOptions struct {
+		MaxSize  int64            // Maximum size of the body, zero for no limit.
+		Progress ProgressFunc     // Called as the body is read.
+		Checksum string           // Expected hex encoded digest of the body, verified when the body has been read.
+		Hash     func() hash.Hash // Hash used to verify the checksum, defaults to SHA-256.
+		Resume   bool             // DownloadFile only, set to continue a partial file using a Range request.
+	}
+
+	// progressReader reports progress and enforces a size limit as a body is read, verifying its checksum at the end.
+	progressReader struct {
+		body        io.ReadCloser
+		opts        *DownloadOptions
+		hash        hash.Hash
+		transferred int64
+		total       int64
+	}
+)
+
+// UploadFile returns an Upload streaming the file.
+func UploadFile(path, contentType string) (*Upload, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, requestBodyError(err.Error())
+	}
+
+	return &Upload{
+		Open:        func() (io.ReadCloser, error) { return os.Open(path) }, // nolint:gosec // ok
+		ContentType: contentType,
+		Size:        info.Size(),
+	}, nil
+}
+
+// reader opens the upload for an attempt.
+func (u *Upload) reader() (io.ReadCloser, string, error) {
+	body, err := u.Open()
+	if err != nil {
+		return nil, "", requestBodyError(err.Error())
+	}
+
+	contentType := u.ContentType
+	if len(contentType) == 0 {
+		contentType = AppOctetStream
+	}
+
+	total := u.Size
+	if total <= 0 {
+		total = -1
+	}
+
+	return &progressReader{body: body, opts: &DownloadOptions{Progress: u.Progress}, total: total}, contentType, nil
+}
+
+// Stream sends the request and returns the response body as it is received, the caller must close it.
+// The body is not buffered so the request timeout, which includes reading the body, should allow for its size.
+// If the server returns an error response the error is a *StatusError and no body is returned.
+func Stream(req ReqResp, opts *DownloadOptions) (io.ReadCloser, error) {
+	r, err := asReqResp(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.send(false); err != nil {
+		return nil, err
+	}
+
+	return newProgressReader(r.response(), opts, 0, "")
+}
+
+// Download sends the request and writes the response body to w, returning the number of bytes written.
+func Download(r ReqResp, w io.Writer, opts *DownloadOptions) (int64, error) {
+	body, err := Stream(r, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	defer body.Close()
+
+	return io.Copy(w, body)
+}
+
+// DownloadFile sends the request and writes the response body to a file, returning the size of the file.
+// The body is written to a partial file, path with a ".partial" suffix, which is renamed to path once the download is
+// complete and its size and checksum verified. A partial file failing verification is removed, as is one left by a
+// failed download unless opts.Resume is set. If opts.Resume is set and the partial file exists the request asks for
+// the remainder of the file using a Range header so a failed download can be continued by calling DownloadFile again.
+// The partial file is rewritten if the server does not support ranges. The file at path is only replaced by a
+// verified download. The checksum, size limit and progress cover the whole file.
+func DownloadFile(req ReqResp, path string, opts *DownloadOptions) (int64, error) {
+	r, err := asReqResp(req)
+	if err != nil {
+		return 0, err
+	}
+
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+
+	partial := path + partialSuffix
+
+	size, err := downloadPartial(r, partial, opts)
+	if err != nil {
+		if !opts.Resume || errors.Is(err, ErrorChecksumMismatch) || errors.Is(err, ErrorSizeLimit) {
+			os.Remove(partial) // nolint:errcheck,gosec // ok
+		}
+
+		return size, err
+	}
+
+	if err := os.Rename(partial, path); err != nil {
+		return size, readingResponseBodyError(err.Error())
+	}
+
+	return size, nil
+}
+
+// downloadPartial sends the request and writes the response body to the partial file, continuing an existing partial
+// file if opts.Resume is set.
+func downloadPartial(r *reqResp, partial string, opts *DownloadOptions) (int64, error) {
+	var offset int64
+
+	if opts.Resume {
+		if info, err := os.Stat(partial); err == nil && info.Size() > 0 {
+			offset = info.Size()
+		}
+	}
+
+	err := r.sendRange(offset)
+
+	var statusErr *StatusError
+	if offset > 0 && errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusRequestedRangeNotSatisfiable &&
+		statusErr.Header.Get("Content-Range") == fmt.Sprintf("bytes */%d", offset) {
+		// The file is already complete.
+		return offset, verifyFile(partial, opts)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	resp := r.response()
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+
+	if offset > 0 && resp.StatusCode == http.StatusPartialContent {
+		if start, ok := rangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			r.CloseBody()
+
+			return 0, readingResponseBodyError(fmt.Sprintf("unexpected content range %q", resp.Header.Get("Content-Range")))
+		}
+
+		flags = os.O_WRONLY | os.O_APPEND
+	} else {
+		offset = 0
+	}
+
+	body, err := newProgressReader(resp, opts, offset, partial)
+	if err != nil {
+		return 0, err
+	}
+
+	defer body.Close()
+
+	file, err := os.OpenFile(partial, flags, fileMode) // nolint:gosec // ok
+	if err != nil {
+		return 0, readingResponseBodyError(err.Error())
+	}
+
+	written, err := io.Copy(file, body)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = readingResponseBodyError(closeErr.Error())
+	}
+
+	return offset + written, err
+}
+
+// sendRange sends the request asking for the content from offset if it is not zero. The Range header is only added
+// to this send so the request can be sent again without it.
+func (r *reqResp) sendRange(offset int64) error {
+	if offset == 0 {
+		return r.send(false)
+	}
+
+	previous, set := r.headerFields["Range"]
+	r.setHeader("Range", fmt.Sprintf("bytes=%d-", offset))
+
+	defer func() {
+		if set {
+			r.setHeader("Range", previous)
+		} else {
+			delete(r.headerFields, "Range")
+		}
+	}()
+
+	return r.send(false)
+}
+
+// newProgressReader returns a reader for the response body. If offset is not zero the body continues the file at
+// path, which is read to initialize the checksum.
+func newProgressReader(resp *http.Response, opts *DownloadOptions, offset int64, path string) (io.ReadCloser, error) {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+
+	if opts.MaxSize > 0 && total > opts.MaxSize {
+		resp.Body.Close()
+
+		return nil, sizeLimitError(fmt.Sprintf("size %d exceeds limit %d", total, opts.MaxSize))
+	}
+
+	reader := &progressReader{body: resp.Body, opts: opts, transferred: offset, total: total}
+
+	if len(opts.Checksum) > 0 {
+		reader.hash = newHash(opts)
+
+		if offset > 0 {
+			if err := hashFile(reader.hash, path); err != nil {
+				resp.Body.Close()
+
+				return nil, err
+			}
+		}
+	}
+
+	return reader, nil
+}
+
+// Read implements io.Reader.
+func (p *progressReader) Read(data []byte) (int, error) {
+	n, err := p.body.Read(data)
+	p.transferred += int64(n)
+
+	if p.opts.MaxSize > 0 && p.transferred > p.opts.MaxSize {
+		return n, sizeLimitError(fmt.Sprintf("read %d bytes, limit is %d", p.transferred, p.opts.MaxSize))
+	}
+
+	if p.hash != nil {
+		p.hash.Write(data[:n]) // nolint:errcheck // hash writes do not fail
+	}
+
+	if p.opts.Progress != nil && n > 0 {
+		p.opts.Progress(p.transferred, p.total)
+	}
+
+	if errors.Is(err, io.EOF) && p.hash != nil {
+		if sum := hex.EncodeToString(p.hash.Sum(nil)); !strings.EqualFold(sum, p.opts.Checksum) {
+			return n, checksumError(fmt.Sprintf("expected %s, got %s", p.opts.Checksum, sum))
+		}
+	}
+
+	return n, err
+}
+
+// Close implements io.Closer.
+func (p *progressReader) Close() error {
+	return p.body.Close()
+}
+
+// newHash returns the hash used to verify checksums.
+func newHash(opts *DownloadOptions) hash.Hash {
+	if opts.Hash != nil {
+		return opts.Hash()
+	}
+
+	return sha256.New()
+}
+
+// hashFile adds the contents of the file to the hash.
+func hashFile(h hash.Hash, path string) error {
+	file, err := os.Open(path) // nolint:gosec // ok
+	if err != nil {
+		return readingResponseBodyError(err.Error())
+	}
+
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return readingResponseBodyError(err.Error())
+	}
+
+	return nil
+}
+
+// verifyFile checks the size and checksum of a complete file.
+func verifyFile(path string, opts *DownloadOptions) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return readingResponseBodyError(err.Error())
+	}
+
+	if opts.MaxSize > 0 && info.Size() > opts.MaxSize {
+		return sizeLimitError(fmt.Sprintf("size %d exceeds limit %d", info.Size(), opts.MaxSize))
+	}
+
+	if len(opts.Checksum) == 0 {
+		return nil
+	}
+
+	h := newHash(opts)
+	if err := hashFile(h, path); err != nil {
+		return err
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, opts.Checksum) {
+		return checksumError(fmt.Sprintf("expected %s, got %s", opts.Checksum, sum))
+	}
+
+	return nil
+}
+
+// rangeStart returns the first byte position of a Content-Range header value such as "bytes 100-199/200".
+func rangeStart(contentRange string) (int64, bool) {
+	value := strings.TrimPrefix(contentRange, "bytes ")
+	if value == contentRange {
+		return 0, false
+	}
+
+	end := strings.IndexByte(value, '-')
+	if end < 0 {
+		return 0, false
+	}
+
+	start, err := strconv.ParseInt(value[:end], ten, sixtyFour)
+
+	return start, err == nil
+}
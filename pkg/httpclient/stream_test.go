@@ -0,0 +1,285 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient/mockserver"
+)
+
+// content returns test data and its SHA-256 checksum.
+func content(size int) ([]byte, string) {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return data, hex.EncodeToString(sum[:])
+}
+
+// contentServer serves the data at /file, supporting range requests, and without a content length at /chunked.
+// Requests to /upload respond with the size and checksum of the body, failing the first attempt.
+func contentServer(t *testing.T, data []byte) (*httptest.Server, *atomic.Value) {
+	var uploads int32
+
+	ranges := &atomic.Value{}
+	ranges.Store("")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file":
+			ranges.Store(r.Header.Get("Range"))
+			http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+		case "/chunked":
+			w.(http.Flusher).Flush()
+			w.Write(data) // nolint:errcheck // ok
+		case "/upload":
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("failed to read upload, %s", err)
+			}
+
+			if atomic.AddInt32(&uploads, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+
+			sum := sha256.Sum256(body)
+			fmt.Fprintf(w, "%d %s %d %s", len(body), hex.EncodeToString(sum[:]), r.ContentLength, r.Header.Get(httpclient.ContentType))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, ranges
+}
+
+func TestStream(t *testing.T) {
+	data, sum := content(10000)
+	server, _ := contentServer(t, data)
+
+	var transferred, total int64
+
+	progress := func(n, size int64) { transferred, total = n, size }
+
+	tests := []struct {
+		testNum  int
+		path     string
+		opts     *httpclient.DownloadOptions
+		expected error
+		progress string
+	}{
+		{1, "/file", nil, nil, "0/0"},
+		{2, "/file", &httpclient.DownloadOptions{Progress: progress, Checksum: sum}, nil, "10000/10000"},
+		{3, "/chunked", &httpclient.DownloadOptions{Progress: progress, Checksum: sum}, nil, "10000/-1"},
+		{4, "/file", &httpclient.DownloadOptions{Checksum: sum[1:] + "0"}, httpclient.ErrorChecksumMismatch, "0/0"},
+		{5, "/file", &httpclient.DownloadOptions{MaxSize: 9999}, httpclient.ErrorSizeLimit, "0/0"},
+		{6, "/chunked", &httpclient.DownloadOptions{MaxSize: 9999}, httpclient.ErrorSizeLimit, "0/0"},
+		{7, "/missing", nil, httpclient.ErrorRequestFailed, "0/0"},
+	}
+
+	for _, test := range tests {
+		transferred, total = 0, 0
+
+		var buf bytes.Buffer
+
+		r := newGet(t, server.URL+test.path)
+		r.SetRetryPolicy(nil)
+
+		n, err := httpclient.Download(r, &buf, test.opts)
+		if !errors.Is(err, test.expected) || (err == nil) != (test.expected == nil) || fmt.Sprintf("%d/%d", transferred, total) != test.progress {
+			t.Errorf("\nTest: %d\nExpected: %v, progress %s\nGot.....: %v, progress %d/%d",
+				test.testNum, test.expected, test.progress, err, transferred, total)
+		}
+
+		if err == nil && (n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data)) {
+			t.Errorf("\nTest: %d\nExpected %d bytes\nGot.....: %d bytes", test.testNum, len(data), n)
+		}
+	}
+}
+
+func TestDownloadFile(t *testing.T) { // nolint:funlen // ok
+	data, sum := content(10000)
+	server, ranges := contentServer(t, data)
+	dir := t.TempDir()
+
+	tests := []struct {
+		testNum  int
+		existing []byte
+		partial  []byte
+		opts     *httpclient.DownloadOptions
+		expected error
+		rng      string
+		file     []byte
+	}{
+		{1, nil, nil, &httpclient.DownloadOptions{Resume: true, Checksum: sum}, nil, "", data},
+		{2, nil, data[:4000], &httpclient.DownloadOptions{Resume: true, Checksum: sum}, nil, "bytes=4000-", data},
+		{3, nil, data, &httpclient.DownloadOptions{Resume: true, Checksum: sum}, nil, "bytes=10000-", data},
+		{4, data[:4000], nil, &httpclient.DownloadOptions{Checksum: sum}, nil, "", data},
+		{5, nil, []byte("corrupt"), &httpclient.DownloadOptions{Resume: true, Checksum: sum}, httpclient.ErrorChecksumMismatch, "bytes=7-", nil},
+		{6, nil, data, &httpclient.DownloadOptions{Resume: true, Checksum: sum, MaxSize: 100}, httpclient.ErrorSizeLimit, "bytes=10000-", nil},
+		{7, []byte("old"), nil, &httpclient.DownloadOptions{Checksum: sum[1:] + "0"}, httpclient.ErrorChecksumMismatch, "", []byte("old")},
+		{8, []byte("old"), data[:6000], &httpclient.DownloadOptions{Resume: true, Checksum: sum}, nil, "bytes=6000-", data},
+		{9, []byte("old"), data[:6000], &httpclient.DownloadOptions{Checksum: sum}, nil, "", data},
+		// An existing file is not resumed and is kept if the download fails.
+		{10, []byte("old"), nil, &httpclient.DownloadOptions{Resume: true, Checksum: sum[1:] + "0"}, httpclient.ErrorChecksumMismatch, "", []byte("old")},
+		{11, data[:4000], nil, &httpclient.DownloadOptions{Resume: true, Checksum: sum}, nil, "", data},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("file-%d", test.testNum))
+		if test.existing != nil {
+			writeFile(t, path, test.existing, time.Now())
+		}
+
+		if test.partial != nil {
+			writeFile(t, path+".partial", test.partial, time.Now())
+		}
+
+		r := newGet(t, server.URL+"/file")
+		r.SetRetryPolicy(nil)
+
+		n, err := httpclient.DownloadFile(r, path, test.opts)
+		if !errors.Is(err, test.expected) || (err == nil) != (test.expected == nil) || ranges.Load() != test.rng {
+			t.Errorf("\nTest: %d\nExpected: %v, range %q\nGot.....: %v, range %q", test.testNum, test.expected, test.rng, err, ranges.Load())
+		}
+
+		if err == nil && n != int64(len(data)) {
+			t.Errorf("\nTest: %d\nExpected %d bytes\nGot.....: %d bytes", test.testNum, len(data), n)
+		}
+
+		if written, readErr := ioutil.ReadFile(path); !bytes.Equal(written, test.file) || (readErr != nil) != (test.file == nil) {
+			t.Errorf("\nTest: %d\nExpected file of %d bytes\nGot.....: %d bytes, %v", test.testNum, len(test.file), len(written), readErr)
+		}
+
+		if _, statErr := os.Stat(path + ".partial"); !errors.Is(statErr, os.ErrNotExist) {
+			t.Errorf("\nTest: %d\nExpected partial file to be removed\nGot.....: %v", test.testNum, statErr)
+		}
+
+		// The Range header is not left on the request.
+		if err := r.HTTPreq(); err != nil || ranges.Load() != "" {
+			t.Errorf("\nTest: %d\nExpected request to be resent without a range\nGot.....: %v, range %q", test.testNum, err, ranges.Load())
+		}
+	}
+}
+
+func TestUpload(t *testing.T) {
+	data, sum := content(10000)
+	server, _ := contentServer(t, data)
+	path := filepath.Join(t.TempDir(), "upload")
+	writeFile(t, path, data, time.Now())
+
+	upload, err := httpclient.UploadFile(path, "application/x-tar")
+	if err != nil {
+		t.Fatalf("failed to create upload, %s", err)
+	}
+
+	var transferred int64
+
+	upload.Progress = func(n, total int64) {
+		if total == int64(len(data)) {
+			atomic.StoreInt64(&transferred, n)
+		}
+	}
+
+	client, err := httpclient.NewClient(httpclient.WithRetryPolicy(fastPolicy()))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	r, err := client.Put(context.Background(), server.URL+"/upload", upload).Do()
+
+	expected := fmt.Sprintf("10000 %s 10000 application/x-tar", sum)
+	if err != nil || r.RespBody() != expected || r.Metadata().Attempts != 2 || atomic.LoadInt64(&transferred) != int64(len(data)) {
+		t.Errorf("\nExpected: %s after 2 attempts\nGot.....: %v after %d attempts, %d bytes sent",
+			expected, err, r.Metadata().Attempts, atomic.LoadInt64(&transferred))
+	}
+
+	if _, err := httpclient.UploadFile(filepath.Join(t.TempDir(), "missing"), ""); !errors.Is(err, httpclient.ErrorRequestBodyInvalid) {
+		t.Errorf("expected request body error, got %v", err)
+	}
+}
+
+// closeCounter is an io.ReadCloser counting the times it is closed.
+type closeCounter struct {
+	io.Reader
+	closed *int32
+}
+
+func (c closeCounter) Close() error {
+	atomic.AddInt32(c.closed, 1)
+
+	return nil
+}
+
+func TestUploadNotSent(t *testing.T) {
+	server := mockserver.New(t)
+	server.Expect("", "/upload").Respond(mockserver.Status(http.StatusServiceUnavailable))
+
+	limiter := httpclient.NewRateLimiter(&httpclient.RateLimiterOptions{Rate: 0.1})
+	if err := limiter.Wait(context.Background(), "host"); err != nil {
+		t.Fatalf("failed to take token, %s", err)
+	}
+
+	breaker := httpclient.NewCircuitBreaker(&httpclient.BreakerOptions{FailureThreshold: 1, CoolDown: time.Minute})
+
+	opener, err := httpclient.NewClient(httpclient.WithCircuitBreaker(breaker), httpclient.WithRetryPolicy(nil))
+	if err != nil {
+		t.Fatalf("failed to create client, %s", err)
+	}
+
+	if _, err := opener.Get(context.Background(), server.URL+"/upload").Do(); err == nil {
+		t.Fatalf("expected request to fail and open the circuit")
+	}
+
+	tests := []struct {
+		testNum  int
+		option   httpclient.ClientOption
+		expected error
+	}{
+		{1, httpclient.WithRateLimiter(limiter), context.DeadlineExceeded},
+		{2, httpclient.WithCircuitBreaker(breaker), httpclient.ErrorCircuitOpen},
+	}
+
+	for _, test := range tests {
+		var closed int32
+
+		upload := &httpclient.Upload{Open: func() (io.ReadCloser, error) {
+			return closeCounter{Reader: bytes.NewReader([]byte("data")), closed: &closed}, nil
+		}}
+
+		client, err := httpclient.NewClient(test.option, httpclient.WithRetryPolicy(nil))
+		if err != nil {
+			t.Fatalf("failed to create client, %s", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		_, err = client.Put(ctx, server.URL+"/upload", upload).Do()
+
+		cancel()
+
+		if !errors.Is(err, test.expected) || atomic.LoadInt32(&closed) != 1 {
+			t.Errorf("\nTest: %d\nExpected: %v, body closed once\nGot.....: %v, body closed %d times", test.testNum, test.expected,
+				err, atomic.LoadInt32(&closed))
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrorTLSConfig = errors.New("invalid TLS configuration")
+
+func tlsConfigError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrorTLSConfig, msg)
+}
+
+// TLSOptions holds the TLS settings of a Client.
+type TLSOptions struct {
+	CAFile             string        // PEM file of CA certificates used to verify servers instead of the system roots.
+	CAData             []byte        // PEM CA certificates used to verify servers, added to those in CAFile.
+	CertFile           string        // PEM client certificate file for mutual TLS.
+	KeyFile            string        // PEM client key file for mutual TLS.
+	ServerName         string        // Name used to verify the server certificate instead of the host name.
+	MinVersion         uint16        // Minimum TLS version, defaults to TLS 1.2.
+	InsecureSkipVerify bool          // Disable verification of the server certificate, for testing only.
+	ReloadInterval     time.Duration // How often the files are checked for changes, zero to never reload them.
+}
+
+// tlsFiles holds the CA pool and client certificate loaded from files, reloading them when they change.
+type tlsFiles struct {
+	mutex     sync.Mutex
+	opts      TLSOptions
+	pool      *x509.CertPool
+	cert      *tls.Certificate
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+// WithTLS sets the TLS configuration of the client's transport, which must be an *http.Transport.
+func WithTLS(opts *TLSOptions) ClientOption {
+	return func(c *Client) error {
+		config, err := NewTLSConfig(opts)
+		if err != nil {
+			return err
+		}
+
+		c.tlsConfig = config
+
+		return nil
+	}
+}
+
+// NewTLSConfig returns a TLS configuration for the options.
+// If ReloadInterval is set the CA and client certificate files are checked for changes at that interval
+// during TLS handshakes so rotated certificates are used without recreating the client.
+func NewTLSConfig(opts *TLSOptions) (*tls.Config, error) {
+	files := &tlsFiles{opts: *opts, modTimes: map[string]time.Time{}}
+	if err := files.load(); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		ServerName:         opts.ServerName,
+		MinVersion:         opts.MinVersion,
+		InsecureSkipVerify: opts.InsecureSkipVerify, // nolint:gosec // ok
+	}
+
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+
+	if len(opts.CertFile) > 0 {
+		config.GetClientCertificate = files.clientCertificate
+	}
+
+	if files.pool != nil && !opts.InsecureSkipVerify {
+		if opts.ReloadInterval > 0 && len(opts.CAFile) > 0 {
+			// Verify using the current CA pool rather than a fixed RootCAs so a rotated CA file takes effect.
+			config.InsecureSkipVerify = true // nolint:gosec // verification is done by VerifyConnection
+			config.VerifyConnection = files.verifyConnection
+		} else {
+			config.RootCAs = files.pool
+		}
+	}
+
+	return config, nil
+}
+
+// applyTLSConfig sets the TLS configuration on a copy of the transport. If the configuration verifies connections
+// itself the transport dials TLS connections so the dialed host can be verified, the TLS handshake does not record
+// IP addresses.
+func applyTLSConfig(transport http.RoundTripper, config *tls.Config) (http.RoundTripper, error) {
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		return nil, tlsConfigError(fmt.Sprintf("transport is a %T, not an *http.Transport", transport))
+	}
+
+	t = t.Clone()
+	t.TLSClientConfig = config
+	t.ForceAttemptHTTP2 = true
+
+	if config.VerifyConnection != nil {
+		t.DialTLSContext = dialTLS(t.DialContext, config, t.TLSHandshakeTimeout)
+	}
+
+	return t, nil
+}
+
+// dialTLS returns a function dialing TLS connections whose server name defaults to the dialed host, including
+// IP addresses, so VerifyConnection can verify it.
+func dialTLS(dial func(ctx context.Context, network, addr string) (net.Conn, error), config *tls.Config,
+	timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg := config.Clone()
+		if len(cfg.ServerName) == 0 {
+			cfg.ServerName = host
+		}
+
+		if len(cfg.NextProtos) == 0 {
+			cfg.NextProtos = []string{"h2", "http/1.1"}
+		}
+
+		verify := cfg.VerifyConnection
+		cfg.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.ServerName) == 0 {
+				state.ServerName = cfg.ServerName
+			}
+
+			return verify(state)
+		}
+
+		if timeout > 0 {
+			var cancel context.CancelFunc
+
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close() // nolint:errcheck,gosec // ok
+
+			return nil, err
+		}
+
+		return tlsConn, nil
+	}
+}
+
+// load reads the files, it is called at creation and when reloading.
+func (f *tlsFiles) load() error {
+	if len(f.opts.CAFile) > 0 || len(f.opts.CAData) > 0 {
+		pool := x509.NewCertPool()
+
+		if len(f.opts.CAFile) > 0 {
+			data, err := ioutil.ReadFile(f.opts.CAFile)
+			if err != nil {
+				return tlsConfigError(err.Error())
+			}
+
+			if !pool.AppendCertsFromPEM(data) {
+				return tlsConfigError(fmt.Sprintf("no certificates found in %s", f.opts.CAFile))
+			}
+		}
+
+		if len(f.opts.CAData) > 0 && !pool.AppendCertsFromPEM(f.opts.CAData) {
+			return tlsConfigError("no certificates found in CA data")
+		}
+
+		f.pool = pool
+	}
+
+	if len(f.opts.CertFile) > 0 || len(f.opts.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(f.opts.CertFile, f.opts.KeyFile)
+		if err != nil {
+			return tlsConfigError(err.Error())
+		}
+
+		f.cert = &cert
+	}
+
+	for _, name := range []string{f.opts.CAFile, f.opts.CertFile, f.opts.KeyFile} {
+		if info, err := os.Stat(name); err == nil {
+			f.modTimes[name] = info.ModTime()
+		}
+	}
+
+	f.lastCheck = time.Now()
+
+	return nil
+}
+
+// reload reloads the files if the reload interval has passed and any of them have changed.
+// If reloading fails the previously loaded certificates continue to be used.
+func (f *tlsFiles) reload() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.opts.ReloadInterval <= 0 || time.Since(f.lastCheck) < f.opts.ReloadInterval {
+		return
+	}
+
+	f.lastCheck = time.Now()
+
+	for name, modTime := range f.modTimes {
+		if info, err := os.Stat(name); err == nil && !info.ModTime().Equal(modTime) {
+			pool, cert := f.pool, f.cert
+
+			if err := f.load(); err != nil {
+				f.pool, f.cert = pool, cert
+			}
+
+			return
+		}
+	}
+}
+
+// clientCertificate implements tls.Config.GetClientCertificate.
+func (f *tlsFiles) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	f.reload()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.cert, nil
+}
+
+// verifyConnection implements tls.Config.VerifyConnection, verifying the server certificate using the current CA pool.
+// Verification fails if there is no server name, which must be a host name or IP address in the certificate.
+func (f *tlsFiles) verifyConnection(state tls.ConnectionState) error {
+	f.reload()
+
+	f.mutex.Lock()
+	pool := f.pool
+	f.mutex.Unlock()
+
+	if len(state.PeerCertificates) == 0 {
+		return tlsConfigError("server presented no certificates")
+	}
+
+	serverName := f.opts.ServerName
+	if len(serverName) == 0 {
+		serverName = state.ServerName
+	}
+
+	if len(serverName) == 0 {
+		return tlsConfigError("no server name to verify the server certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         pool,
+		Intermediates: intermediates,
+	})
+
+	return err
+}
@@ -0,0 +1,265 @@
+package httpclient_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpclient"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var serial int64 // nolint:gochecknoglobals // ok
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key, %s", err)
+	}
+
+	return key
+}
+
+func newCA(t *testing.T) *testCA {
+	key := newKey(t)
+	serial++
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate, %s", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate, %s", err)
+	}
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate and key in PEM format signed by the CA.
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage, dnsNames []string, ips []net.IP) ([]byte, []byte) {
+	key := newKey(t)
+	serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create certificate, %s", err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key, %s", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func writeFile(t *testing.T, name string, data []byte, modTime time.Time) {
+	if err := ioutil.WriteFile(name, data, 0o600); err != nil {
+		t.Fatalf("failed to write %s, %s", name, err)
+	}
+
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatalf("failed to set time of %s, %s", name, err)
+	}
+}
+
+// mtlsServer returns a TLS server requiring client certificates issued by the CA, it responds with the client's name.
+func mtlsServer(t *testing.T, ca *testCA, dnsNames []string, ips []net.IP, maxVersion uint16) *httptest.Server {
+	certPEM, keyPEM := ca.issue(t, "server", x509.ExtKeyUsageServerAuth, dnsNames, ips)
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("failed to load server certificate, %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MaxVersion:   maxVersion,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func getWithTLS(t *testing.T, url string, opts *httpclient.TLSOptions) (string, *httpclient.Client, error) {
+	options := []httpclient.ClientOption{httpclient.WithRetryPolicy(nil)}
+	if opts != nil {
+		options = append(options, httpclient.WithTLS(opts))
+	}
+
+	client, err := httpclient.NewClient(options...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	r, err := client.Get(context.Background(), url).Do()
+	if err != nil {
+		return "", client, err
+	}
+
+	return r.RespBody(), client, nil
+}
+
+func TestTLS(t *testing.T) { // nolint:funlen // ok
+	dir := t.TempDir()
+	ca := newCA(t)
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	clientCert, clientKey := ca.issue(t, "client-1", x509.ExtKeyUsageClientAuth, nil, nil)
+	past := time.Now().Add(-time.Minute)
+
+	writeFile(t, caFile, ca.pem, past)
+	writeFile(t, certFile, clientCert, past)
+	writeFile(t, keyFile, clientKey, past)
+
+	server := mtlsServer(t, ca, nil, []net.IP{net.ParseIP("127.0.0.1")}, 0)
+	namedServer := mtlsServer(t, ca, []string{"service.internal"}, nil, 0)
+	oldServer := mtlsServer(t, ca, nil, []net.IP{net.ParseIP("127.0.0.1")}, tls.VersionTLS12)
+	otherIPServer := mtlsServer(t, ca, nil, []net.IP{net.ParseIP("10.9.9.9")}, 0)
+	reload := &httpclient.TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ReloadInterval: time.Hour}
+
+	tests := []struct {
+		testNum  int
+		url      string
+		opts     *httpclient.TLSOptions
+		expected string
+	}{
+		{1, server.URL, nil, ""},
+		{2, server.URL, &httpclient.TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, "client-1"},
+		{3, server.URL, &httpclient.TLSOptions{CAData: ca.pem, CertFile: certFile, KeyFile: keyFile}, "client-1"},
+		{4, server.URL, &httpclient.TLSOptions{CAFile: caFile}, ""},
+		{5, namedServer.URL, &httpclient.TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, ""},
+		{6, namedServer.URL, &httpclient.TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "service.internal"}, "client-1"},
+		{7, namedServer.URL, &httpclient.TLSOptions{
+			CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "service.internal", ReloadInterval: time.Hour,
+		}, "client-1"},
+		{8, oldServer.URL, &httpclient.TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, "client-1"},
+		{9, oldServer.URL, &httpclient.TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, MinVersion: tls.VersionTLS13}, ""},
+		{10, server.URL, &httpclient.TLSOptions{CertFile: certFile, KeyFile: keyFile, InsecureSkipVerify: true}, "client-1"},
+		{11, server.URL, reload, "client-1"},
+		{12, otherIPServer.URL, &httpclient.TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, ""},
+		{13, otherIPServer.URL, reload, ""},
+		{14, namedServer.URL, reload, ""},
+	}
+
+	for _, test := range tests {
+		result, _, err := getWithTLS(t, test.url, test.opts)
+		if result != test.expected || (err != nil) != (len(test.expected) == 0) {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %s, %v", test.testNum, test.expected, result, err)
+		}
+	}
+}
+
+func TestTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	past := time.Now().Add(-time.Minute)
+	clientCert, clientKey := ca.issue(t, "client-1", x509.ExtKeyUsageClientAuth, nil, nil)
+
+	writeFile(t, caFile, ca.pem, past)
+	writeFile(t, certFile, clientCert, past)
+	writeFile(t, keyFile, clientKey, past)
+
+	opts := &httpclient.TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ReloadInterval: time.Millisecond}
+	server := mtlsServer(t, ca, nil, []net.IP{net.ParseIP("127.0.0.1")}, 0)
+
+	result, client, err := getWithTLS(t, server.URL, opts)
+	if err != nil || result != "client-1" {
+		t.Fatalf("\nExpected: client-1\nGot.....: %s, %v", result, err)
+	}
+
+	// Rotate the client certificate and the CA, replacing the server with one using a certificate from the new CA.
+	newAuthority := newCA(t)
+	clientCert, clientKey = newAuthority.issue(t, "client-2", x509.ExtKeyUsageClientAuth, nil, nil)
+
+	writeFile(t, caFile, newAuthority.pem, time.Now())
+	writeFile(t, certFile, clientCert, time.Now())
+	writeFile(t, keyFile, clientKey, time.Now())
+	time.Sleep(5 * time.Millisecond)
+
+	newServer := mtlsServer(t, newAuthority, nil, []net.IP{net.ParseIP("127.0.0.1")}, 0)
+
+	r, err := client.Get(context.Background(), newServer.URL).Do()
+	if err != nil || r.RespBody() != "client-2" {
+		t.Errorf("\nExpected: client-2\nGot.....: %v", err)
+	}
+
+	client.Transport().(*http.Transport).CloseIdleConnections()
+
+	if _, err := client.Get(context.Background(), server.URL).Do(); err == nil {
+		t.Errorf("expected server with certificate from the old CA to be rejected")
+	}
+}
+
+func TestTLSErrors(t *testing.T) {
+	dir := t.TempDir()
+	badFile := filepath.Join(dir, "bad.pem")
+	writeFile(t, badFile, []byte("not a certificate"), time.Now())
+
+	tests := []struct {
+		testNum int
+		options []httpclient.ClientOption
+	}{
+		{1, []httpclient.ClientOption{httpclient.WithTLS(&httpclient.TLSOptions{CAFile: badFile})}},
+		{2, []httpclient.ClientOption{httpclient.WithTLS(&httpclient.TLSOptions{CAFile: filepath.Join(dir, "missing.pem")})}},
+		{3, []httpclient.ClientOption{httpclient.WithTLS(&httpclient.TLSOptions{CertFile: badFile, KeyFile: badFile})}},
+		{4, []httpclient.ClientOption{httpclient.WithTLS(&httpclient.TLSOptions{}), httpclient.WithTransport(http.NewFileTransport(http.Dir(dir)))}},
+	}
+
+	for _, test := range tests {
+		if _, err := httpclient.NewClient(test.options...); !errors.Is(err, httpclient.ErrorTLSConfig) {
+			t.Errorf("\nTest: %d\nExpected: ErrorTLSConfig\nGot.....: %v", test.testNum, err)
+		}
+	}
+}
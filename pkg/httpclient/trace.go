@@ -0,0 +1,260 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/logging"
+)
+
+const (
+	defaultMaxBodyLog = 4096
+	redacted          = "[REDACTED]"
+	redactedPassword  = "xxxxx"
+)
+
+var (
+	// DefaultRedactHeaders are the headers whose values are always redacted when logged.
+	DefaultRedactHeaders = []string{ // nolint:gochecknoglobals // ok
+		"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token",
+	}
+	// DefaultRedactFields are the JSON and form fields whose values are always redacted when logged.
+	DefaultRedactFields = []string{ // nolint:gochecknoglobals // ok
+		"password", "secret", "token", "access_token", "refresh_token", "id_token", "client_secret", "api_key", "apiKey",
+	}
+	// DefaultRedactQuery are the URL query parameters whose values are always redacted when logged.
+	DefaultRedactQuery = []string{ // nolint:gochecknoglobals // ok
+		"access_token", "id_token", "refresh_token", "token", "api_key", "apikey", "client_secret", "password",
+		"sig", "signature", "X-Amz-Signature", "X-Amz-Credential", "X-Amz-Security-Token",
+	}
+)
+
+// LogOptions controls the logging of requests and responses. Each attempt's method, URL, headers, status, duration
+// and attempt number are logged at logging.GetTraceLevel(), bodies one level above it if enabled.
+// Sensitive headers, fields, query parameters and URL passwords are redacted.
+type LogOptions struct {
+	Bodies        bool     // Set to log request and response bodies.
+	MaxBodySize   int      // Maximum number of body bytes logged, defaults to 4096.
+	RedactHeaders []string // Headers redacted in addition to DefaultRedactHeaders.
+	RedactFields  []string // JSON and form fields redacted in addition to DefaultRedactFields, matched case insensitively.
+	RedactQuery   []string // Query parameters redacted in addition to DefaultRedactQuery, matched case insensitively.
+}
+
+// RedactHeader returns a copy of the header with the values of sensitive headers replaced.
+func (o *LogOptions) RedactHeader(header http.Header) http.Header {
+	result := header.Clone()
+
+	for _, names := range [][]string{DefaultRedactHeaders, o.RedactHeaders} {
+		for _, name := range names {
+			if _, ok := result[http.CanonicalHeaderKey(name)]; ok {
+				result.Set(name, redacted)
+			}
+		}
+	}
+
+	return result
+}
+
+// RedactURL returns a copy of the URL with the password and the values of sensitive query parameters replaced.
+// The order of the query parameters is preserved.
+func (o *LogOptions) RedactURL(u *url.URL) *url.URL {
+	result := *u
+
+	if password, ok := u.User.Password(); ok && len(password) > 0 {
+		result.User = url.UserPassword(u.User.Username(), redactedPassword)
+	}
+
+	if len(u.RawQuery) == 0 {
+		return &result
+	}
+
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		key, _, found := strings.Cut(param, "=")
+
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+
+		if found && matchName(name, DefaultRedactQuery, o.RedactQuery) {
+			params[i] = key + "=" + redacted
+		}
+	}
+
+	result.RawQuery = strings.Join(params, "&")
+
+	return &result
+}
+
+// RedactJSON returns the JSON document with the values of sensitive fields replaced at any depth.
+// Data that is not valid JSON is returned unchanged.
+func (o *LogOptions) RedactJSON(data []byte) []byte {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return data
+	}
+
+	result, err := json.Marshal(o.redactValue(doc))
+	if err != nil {
+		return data
+	}
+
+	return result
+}
+
+// RedactForm returns the form encoded body with the values of sensitive fields replaced.
+// Data that is not a valid form is returned unchanged.
+func (o *LogOptions) RedactForm(data []byte) []byte {
+	form, err := url.ParseQuery(string(data))
+	if err != nil {
+		return data
+	}
+
+	for name := range form {
+		if o.sensitiveField(name) {
+			form.Set(name, redacted)
+		}
+	}
+
+	return []byte(form.Encode())
+}
+
+// redactValue replaces the values of sensitive fields in a decoded JSON value.
+func (o *LogOptions) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if o.sensitiveField(key) {
+				v[key] = redacted
+			} else {
+				v[key] = o.redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = o.redactValue(item)
+		}
+	}
+
+	return value
+}
+
+// sensitiveField returns true if the field's value should be redacted.
+func (o *LogOptions) sensitiveField(name string) bool {
+	return matchName(name, DefaultRedactFields, o.RedactFields)
+}
+
+// matchName returns true if the name is in any of the lists, ignoring case.
+func matchName(name string, lists ...[]string) bool {
+	for _, names := range lists {
+		for _, n := range names {
+			if strings.EqualFold(name, n) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// body returns a body for logging, redacted and truncated to the maximum size.
+func (o *LogOptions) body(data []byte, contentType string) string {
+	switch {
+	case strings.Contains(contentType, "json"):
+		data = o.RedactJSON(data)
+	case strings.HasPrefix(contentType, AppForm):
+		data = o.RedactForm(data)
+	case len(contentType) > 0 && !strings.HasPrefix(contentType, "text/") && !strings.Contains(contentType, "xml"):
+		return fmt.Sprintf("<%d bytes of %s>", len(data), contentType)
+	}
+
+	maxSize := o.MaxBodySize
+	if maxSize <= 0 {
+		maxSize = defaultMaxBodyLog
+	}
+
+	if len(data) > maxSize {
+		return fmt.Sprintf("%s... (%d bytes truncated)", data[:maxSize], len(data)-maxSize)
+	}
+
+	return string(data)
+}
+
+// SetLogOptions sets the options controlling the logging of requests and responses, nil uses the defaults.
+func (r *reqResp) SetLogOptions(opts *LogOptions) {
+	if opts == nil {
+		opts = &LogOptions{}
+	}
+
+	r.logOptions = opts
+}
+
+// redactedURL returns the URL for logging with sensitive values redacted.
+func (r *reqResp) redactedURL(u *url.URL) string {
+	return r.logOptions.RedactURL(u).String()
+}
+
+// redactedError returns the error for logging with sensitive values redacted from the URL of a *url.Error.
+func (r *reqResp) redactedError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		return err
+	}
+
+	redactedErr := &url.Error{Op: urlErr.Op, URL: r.redactedURL(u), Err: urlErr.Err}
+	if err == error(urlErr) {
+		return redactedErr
+	}
+
+	return errors.New(strings.ReplaceAll(err.Error(), urlErr.Error(), redactedErr.Error()))
+}
+
+// logRequest logs an attempt's request.
+func (r *reqResp) logRequest(req *http.Request) {
+	log := r.logger.V(logging.GetTraceLevel())
+	if !log.Enabled() {
+		return
+	}
+
+	log.Info("sending request", "method", req.Method, "url", r.redactedURL(req.URL), "attempt", r.attempts,
+		"header", r.logOptions.RedactHeader(req.Header))
+
+	if r.logOptions.Bodies && len(r.bodyData) > 0 {
+		r.logger.V(logging.GetTraceLevel()+1).Info("request body", "url", r.redactedURL(req.URL), "attempt", r.attempts,
+			"body", r.logOptions.body(r.bodyData, r.contentType))
+	}
+}
+
+// logResponse logs an attempt's response or error, the body is only logged if it has been read.
+func (r *reqResp) logResponse(duration time.Duration, err error) {
+	log := r.logger.V(logging.GetTraceLevel())
+	if !log.Enabled() {
+		return
+	}
+
+	if err != nil {
+		log.Info("request failed", "method", *r.method, "url", r.redactedURL(r.url), "attempt", r.attempts,
+			"duration", duration, "error", r.redactedError(err).Error())
+
+		return
+	}
+
+	log.Info("received response", "method", *r.method, "url", r.redactedURL(r.url), "attempt", r.attempts,
+		"duration", duration, "status", r.resp.StatusCode, "header", r.logOptions.RedactHeader(r.resp.Header))
+
+	if r.logOptions.Bodies && r.respText != nil {
+		r.logger.V(logging.GetTraceLevel()+1).Info("response body", "url", r.redactedURL(r.url), "attempt", r.attempts,
+			"body", r.logOptions.body([]byte(*r.respText), r.resp.Header.Get(ContentType)))
+	}
+}
@@ -0,0 +1,57 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+const bearerPrefix = "Bearer "
+
+// AuthOptions holds the credentials a client must present, requests are authorized if they match any of them.
+type AuthOptions struct {
+	Username     string   // Basic authentication user name.
+	Password     string   // Basic authentication password.
+	BearerTokens []string // Tokens accepted in a 'Authorization: Bearer <token>' header.
+}
+
+// Authorized returns true if the request presents credentials matching the options, or no options are set.
+func (auth *AuthOptions) Authorized(r *http.Request) bool {
+	if auth == nil {
+		return true
+	}
+
+	if user, password, ok := r.BasicAuth(); ok && len(auth.Username) > 0 {
+		return secureEqual(user, auth.Username) && secureEqual(password, auth.Password)
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return false
+	}
+
+	token := strings.TrimPrefix(header, bearerPrefix)
+
+	for _, bearer := range auth.BearerTokens {
+		if secureEqual(token, bearer) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unauthorized sends a 401 response asking the client for credentials.
+func (auth *AuthOptions) unauthorized(w http.ResponseWriter) {
+	if len(auth.Username) > 0 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="restricted"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
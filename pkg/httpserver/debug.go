@@ -0,0 +1,120 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	rtdebug "runtime/debug"
+	rtpprof "runtime/pprof"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/paulcarlton-ww/goutils/pkg/logging"
+)
+
+const (
+	// DebugPrefix is the path prefix of the debug routes served when HandlerHTTP.Debug is set.
+	DebugPrefix = "/debug/"
+	// goroutineDebug is the debug level giving a full stack dump of all goroutines.
+	goroutineDebug = 2
+)
+
+// LogLevel is the request and response body used to get or set the trace level.
+type LogLevel struct {
+	TraceLevel int `json:"traceLevel"`
+}
+
+// isDebugPath returns true if the path is handled by the debug routes.
+func (handler *HandlerHTTP) isDebugPath(path string) bool {
+	return handler.Debug && strings.HasPrefix(path, DebugPrefix)
+}
+
+// serveDebug serves the debug routes, requests have already been checked against the server's auth options.
+func (handler *HandlerHTTP) serveDebug(w http.ResponseWriter, r *http.Request) {
+	handler.debugOnce.Do(func() {
+		handler.debugMux = newDebugMux()
+	})
+
+	handler.debugMux.ServeHTTP(w, r)
+}
+
+// newDebugMux returns the mux serving the pprof, goroutine, build info and log level routes.
+func newDebugMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc(DebugPrefix+"pprof/", pprof.Index)
+	mux.HandleFunc(DebugPrefix+"pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc(DebugPrefix+"pprof/profile", pprof.Profile)
+	mux.HandleFunc(DebugPrefix+"pprof/symbol", pprof.Symbol)
+	mux.HandleFunc(DebugPrefix+"pprof/trace", pprof.Trace)
+	mux.HandleFunc(DebugPrefix+"goroutines", debugHandler(goroutines))
+	mux.HandleFunc(DebugPrefix+"buildinfo", debugHandler(buildInfo))
+	mux.HandleFunc(DebugPrefix+"loglevel", debugHandler(logLevel))
+
+	return mux
+}
+
+// debugHandler adapts a MuxHTTP style handler function for use by the debug mux.
+func debugHandler(h func(http.ResponseWriter, *http.Request) (int, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if code, msg := h(w, r); code != http.StatusOK {
+			http.Error(w, msg, code)
+		}
+	}
+}
+
+// goroutines writes the stack traces of all goroutines.
+func goroutines(w http.ResponseWriter, r *http.Request) (int, string) {
+	w.Header().Set(ContentType, "text/plain; charset=utf-8")
+
+	if err := rtpprof.Lookup("goroutine").WriteTo(w, goroutineDebug); err != nil {
+		return http.StatusInternalServerError, fmt.Sprintf("failed to write goroutines, %s", err)
+	}
+
+	return http.StatusOK, "OK"
+}
+
+// buildInfo returns the build information embedded in the binary.
+func buildInfo(w http.ResponseWriter, r *http.Request) (int, string) {
+	info, ok := rtdebug.ReadBuildInfo()
+	if !ok {
+		return http.StatusNotFound, "build info not available"
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Sprintf("failed to convert build info to json, %s", err)
+	}
+
+	return JSONresponse(w, string(data))
+}
+
+// logLevel returns the current trace level, or sets it if the request is a PUT or POST.
+func logLevel(w http.ResponseWriter, r *http.Request) (int, string) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		body, err := GetReqBody(r)
+		if err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+
+		level := LogLevel{}
+		if err := json.Unmarshal([]byte(body), &level); err != nil {
+			return http.StatusBadRequest, fmt.Sprintf("invalid log level, %s", err)
+		}
+
+		log.Infof("Changing trace level from %d to %d", logging.GetTraceLevel(), level.TraceLevel)
+		logging.SetTraceLevel(level.TraceLevel)
+	default:
+		return http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method)
+	}
+
+	data, err := json.Marshal(LogLevel{TraceLevel: logging.GetTraceLevel()})
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Sprintf("failed to convert log level to json, %s", err)
+	}
+
+	return JSONresponse(w, string(data))
+}
@@ -0,0 +1,61 @@
+package httpserver_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpserver"
+	"github.com/paulcarlton-ww/goutils/pkg/logging"
+)
+
+func TestDebugRoutes(t *testing.T) {
+	defer logging.SetTraceLevel(logging.GetTraceLevel())
+
+	auth := &httpserver.AuthOptions{BearerTokens: []string{"secret"}}
+	mux := httpserver.MuxHTTP{"/ok": func(w http.ResponseWriter, r *http.Request) (int, string) {
+		return httpserver.JSONresponse(w, `{"ok":true}`)
+	}}
+	tests := []struct {
+		testNum  int
+		handler  *httpserver.HandlerHTTP
+		method   string
+		path     string
+		token    string
+		body     string
+		expected int
+		contains string
+	}{
+		{1, &httpserver.HandlerHTTP{}, http.MethodGet, "/debug/goroutines", "", "", http.StatusBadRequest, "unrecognised request"},
+		{2, &httpserver.HandlerHTTP{Debug: true, Auth: auth}, http.MethodGet, "/debug/goroutines", "", "", http.StatusUnauthorized, "Unauthorized"},
+		{3, &httpserver.HandlerHTTP{Debug: true, Auth: auth}, http.MethodGet, "/debug/goroutines", "wrong", "", http.StatusUnauthorized, "Unauthorized"},
+		{4, &httpserver.HandlerHTTP{Debug: true, Auth: auth}, http.MethodGet, "/debug/goroutines", "secret", "", http.StatusOK, "goroutine"},
+		{5, &httpserver.HandlerHTTP{Debug: true, Auth: auth}, http.MethodGet, "/debug/pprof/", "secret", "", http.StatusOK, "heap"},
+		{6, &httpserver.HandlerHTTP{Debug: true, Auth: auth}, http.MethodGet, "/debug/buildinfo", "secret", "", http.StatusOK, `"Path"`},
+		{7, &httpserver.HandlerHTTP{Debug: true, Auth: auth}, http.MethodPut, "/debug/loglevel", "secret", `{"traceLevel":7}`, http.StatusOK, `{"traceLevel":7}`},
+		{8, &httpserver.HandlerHTTP{Debug: true, Auth: auth}, http.MethodGet, "/debug/loglevel", "secret", "", http.StatusOK, `{"traceLevel":7}`},
+		{9, &httpserver.HandlerHTTP{Debug: true, Auth: auth}, http.MethodPut, "/debug/loglevel", "secret", `seven`, http.StatusBadRequest, "invalid log level"},
+		{10, &httpserver.HandlerHTTP{Debug: true, Auth: auth}, http.MethodDelete, "/debug/loglevel", "secret", "", http.StatusMethodNotAllowed, "not allowed"},
+		{11, &httpserver.HandlerHTTP{Debug: true, Auth: auth, Mux: mux}, http.MethodGet, "/ok", "", "", http.StatusOK, "ok"},
+		{12, &httpserver.HandlerHTTP{Debug: true, Auth: auth, AuthAll: true, Mux: mux}, http.MethodGet, "/ok", "", "", http.StatusUnauthorized, "Unauthorized"},
+		{13, &httpserver.HandlerHTTP{Debug: true, Auth: auth, AuthAll: true, Mux: mux}, http.MethodGet, "/ok", "secret", "", http.StatusOK, "ok"},
+		{14, &httpserver.HandlerHTTP{Debug: true}, http.MethodGet, "/debug/goroutines", "", "", http.StatusForbidden, "require auth options"},
+		{15, &httpserver.HandlerHTTP{Debug: true}, http.MethodPut, "/debug/loglevel", "", `{"traceLevel":9}`, http.StatusForbidden, "require auth options"},
+		{16, &httpserver.HandlerHTTP{Debug: true, Mux: mux}, http.MethodGet, "/ok", "", "", http.StatusOK, "ok"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if len(test.token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		w := httptest.NewRecorder()
+		test.handler.ServeHTTP(w, req)
+
+		if w.Code != test.expected || !strings.Contains(w.Body.String(), test.contains) {
+			t.Errorf("\nTest: %d\nExpected: %d, containing %s\nGot.....: %d, %s", test.testNum, test.expected, test.contains, w.Code, w.Body.String())
+		}
+	}
+}
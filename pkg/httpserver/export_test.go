@@ -0,0 +1,6 @@
+package httpserver
+
+// StartAll exposes startAll so tests can check a failed start without exiting.
+func (handler *HandlerHTTP) StartAll() error {
+	return handler.startAll()
+}
@@ -2,4 +2,14 @@ module github.com/paulcarlton-ww/goutils/pkg/httpserver
 
 go 1.16
 
-require github.com/sirupsen/logrus v1.8.1
+require (
+	github.com/paulcarlton-ww/goutils/pkg/logging v0.0.5
+	github.com/paulcarlton-ww/goutils/pkg/testutils v0.0.5
+	github.com/sirupsen/logrus v1.8.1
+	golang.org/x/net v0.0.0-20210428140749-89ef3d95e781
+)
+
+replace (
+	github.com/paulcarlton-ww/goutils/pkg/logging => ../logging
+	github.com/paulcarlton-ww/goutils/pkg/testutils => ../testutils
+)
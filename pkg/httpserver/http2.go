@@ -0,0 +1,56 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// HTTP2Options holds the settings used to serve HTTP/2 requests.
+type HTTP2Options struct {
+	// H2C enables cleartext HTTP/2 when TLS is not configured, clients can use prior knowledge or an HTTP/1.1 upgrade.
+	H2C bool
+	// MaxConcurrentStreams is the number of concurrent streams each client may have open, zero uses the http2 package default.
+	MaxConcurrentStreams uint32
+	// MaxReadFrameSize is the largest frame the server will read, zero uses the http2 package default.
+	MaxReadFrameSize uint32
+	// IdleTimeout is how long an idle connection is kept open, zero uses the server's IdleTimeout.
+	IdleTimeout time.Duration
+}
+
+// tlsEnabled returns true if the server should serve HTTPS.
+func (handler *HandlerHTTP) tlsEnabled() bool {
+	if len(handler.CertFile) > 0 && len(handler.KeyFile) > 0 {
+		return true
+	}
+
+	return handler.TLSConfig != nil && (len(handler.TLSConfig.Certificates) > 0 || handler.TLSConfig.GetCertificate != nil)
+}
+
+// configureHTTP2 sets up the server to support HTTP/2 over TLS or h2c, or restricts it to HTTP/1.1 if HTTP/2 is not enabled.
+func (handler *HandlerHTTP) configureHTTP2(server *http.Server) error {
+	if handler.HTTP2 == nil {
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+
+		return nil
+	}
+
+	h2s := &http2.Server{
+		MaxConcurrentStreams: handler.HTTP2.MaxConcurrentStreams,
+		MaxReadFrameSize:     handler.HTTP2.MaxReadFrameSize,
+		IdleTimeout:          handler.HTTP2.IdleTimeout,
+	}
+
+	if handler.tlsEnabled() {
+		return http2.ConfigureServer(server, h2s)
+	}
+
+	if handler.HTTP2.H2C {
+		server.Handler = h2c.NewHandler(server.Handler, h2s)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package httpserver_test
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpserver"
+)
+
+func protoMux() httpserver.MuxHTTP {
+	return httpserver.MuxHTTP{
+		"/proto": func(w http.ResponseWriter, r *http.Request) (int, string) {
+			return httpserver.JSONresponse(w, fmt.Sprintf(`{"proto":"%s"}`, r.Proto))
+		},
+	}
+}
+
+func selfSignedConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key, %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate, %s", err)
+	}
+
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}} // nolint:gosec // ok
+}
+
+func startServer(t *testing.T, handler *httpserver.HandlerHTTP) {
+	handler.Address = "127.0.0.1"
+	handler.C = make(chan string, 1)
+	handler.Start(nil)
+
+	t.Cleanup(handler.Shutdown)
+}
+
+func getProto(t *testing.T, client *http.Client, url string) string {
+	resp, err := client.Get(url) // nolint:noctx // ok
+	if err != nil {
+		t.Fatalf("request failed, %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body, %s", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || string(body) != fmt.Sprintf(`{"proto":"%s"}`, resp.Proto) {
+		t.Fatalf("unexpected response: %s, %s", resp.Status, body)
+	}
+
+	return resp.Proto
+}
+
+func h2cClient() *http.Client {
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+}
+
+func TestHTTP2(t *testing.T) {
+	insecure := &tls.Config{InsecureSkipVerify: true} // nolint:gosec // ok
+
+	tests := []struct {
+		testNum  int
+		handler  *httpserver.HandlerHTTP
+		client   *http.Client
+		scheme   string
+		expected string
+	}{
+		{1, &httpserver.HandlerHTTP{Mux: protoMux()}, http.DefaultClient, "http", "HTTP/1.1"},
+		{2, &httpserver.HandlerHTTP{Mux: protoMux(), TLSConfig: selfSignedConfig(t)},
+			&http.Client{Transport: &http.Transport{TLSClientConfig: insecure, ForceAttemptHTTP2: true}}, "https", "HTTP/1.1"},
+		{3, &httpserver.HandlerHTTP{Mux: protoMux(), TLSConfig: selfSignedConfig(t), HTTP2: &httpserver.HTTP2Options{MaxConcurrentStreams: 10}},
+			&http.Client{Transport: &http2.Transport{TLSClientConfig: insecure}}, "https", "HTTP/2.0"},
+		{4, &httpserver.HandlerHTTP{Mux: protoMux(), HTTP2: &httpserver.HTTP2Options{H2C: true}}, h2cClient(), "http", "HTTP/2.0"},
+		{5, &httpserver.HandlerHTTP{Mux: protoMux(), HTTP2: &httpserver.HTTP2Options{H2C: true}}, http.DefaultClient, "http", "HTTP/1.1"},
+	}
+
+	for _, test := range tests {
+		startServer(t, test.handler)
+
+		result := getProto(t, test.client, fmt.Sprintf("%s://%s/proto", test.scheme, test.handler.Addr()))
+		if result != test.expected {
+			t.Errorf("\nTest: %d\nExpected: %s\nGot.....: %s", test.testNum, test.expected, result)
+		}
+	}
+}
+
+func TestH2CUpgrade(t *testing.T) {
+	handler := &httpserver.HandlerHTTP{Mux: protoMux(), HTTP2: &httpserver.HTTP2Options{H2C: true}}
+	startServer(t, handler)
+
+	conn, err := net.Dial("tcp", handler.Addr())
+	if err != nil {
+		t.Fatalf("failed to connect, %s", err)
+	}
+	defer conn.Close()
+
+	_, err = fmt.Fprintf(conn, "GET /proto HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade, HTTP2-Settings\r\n"+
+		"Upgrade: h2c\r\nHTTP2-Settings: AAMAAABkAARAAAAAAAIAAAAA\r\n\r\n", handler.Addr())
+	if err != nil {
+		t.Fatalf("failed to send upgrade request, %s", err)
+	}
+
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read response, %s", err)
+	}
+
+	if !strings.HasPrefix(status, "HTTP/1.1 101") {
+		t.Errorf("\nExpected: HTTP/1.1 101 Switching Protocols\nGot.....: %s", status)
+	}
+}
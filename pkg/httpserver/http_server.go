@@ -1,6 +1,8 @@
 package httpserver
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -8,6 +10,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -35,6 +38,32 @@ type HandlerHTTP struct {
 	C          chan string
 	Mux        MuxHTTP
 	Server     *http.Server
+	// TLSConfig, CertFile and KeyFile enable TLS, the certificate files are only needed if TLSConfig does not contain certificates.
+	TLSConfig *tls.Config
+	CertFile  string
+	KeyFile   string
+	// HTTP2 enables HTTP/2, leave unset to serve HTTP/1.1 only.
+	HTTP2 *HTTP2Options
+	// Auth holds the credentials required for the debug routes, leave unset to allow unauthenticated access.
+	Auth *AuthOptions
+	// AuthAll requires the Auth credentials for all requests, not just the debug routes.
+	AuthAll bool
+	// Debug enables the pprof, goroutine, build info and log level routes under DebugPrefix. They are protected by
+	// Auth, if it is not set requests to them are refused with a 403 response.
+	Debug bool
+	// Name identifies the listener in logs and lookups.
+	Name string
+	// Middleware wraps the handler, the first entry is the outermost.
+	Middleware []Middleware
+	// Idempotency enables replaying responses to requests repeated with the same idempotency key.
+	Idempotency *IdempotencyOptions
+	// Listeners are additional listeners, e.g. admin or metrics, started and shutdown with this one.
+	Listeners []*HandlerHTTP
+
+	mutex     sync.Mutex
+	listener  net.Listener
+	debugOnce sync.Once
+	debugMux  *http.ServeMux
 }
 
 // MuxHTTP is a type defining a map of pathnames to functions for handling incoming http requests.
@@ -58,7 +87,33 @@ func (handler *HandlerHTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ThePath = reqLine
 	}
 
+	debug := handler.isDebugPath(ThePath)
+
+	if debug && handler.Auth == nil {
+		http.Error(w, "debug routes require auth options", http.StatusForbidden)
+
+		return
+	}
+
+	if (debug || handler.AuthAll) && !handler.Auth.Authorized(r) {
+		handler.Auth.unauthorized(w)
+
+		return
+	}
+
+	if debug {
+		handler.serveDebug(w, r)
+
+		return
+	}
+
 	if h, ok := handler.Mux[ThePath]; ok {
+		if key := handler.idempotencyKey(r); len(key) > 0 {
+			handler.serveIdempotent(w, r, key, h)
+
+			return
+		}
+
 		code, msg := h(w, r)
 		if !expectedHTTPstatus(code, r.Method) {
 			http.Error(w, msg, code)
@@ -72,11 +127,36 @@ func (handler *HandlerHTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, fmt.Sprintf("unrecognised request %s", ThePath), http.StatusBadRequest)
 }
 
+// Start creates the listeners and serves HTTP requests on them in the background.
 func (handler *HandlerHTTP) Start(handlers *MuxHTTP) {
-	go serveHTTP(*handler)
+	if handlers != nil {
+		handler.Mux = *handlers
+	}
+
+	if err := handler.startAll(); err != nil {
+		log.Fatalf("unable create listener, %s", err)
+	}
 }
 
+// Addr returns the address the server is listening on, or an empty string if it has not been started.
+func (handler *HandlerHTTP) Addr() string {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
+	if handler.listener == nil {
+		return ""
+	}
+
+	return handler.listener.Addr().String()
+}
+
+// Shutdown gracefully stops the server and its listeners and notifies the owner via the C channel.
 func (handler *HandlerHTTP) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), thirty*time.Second)
+	defer cancel()
+
+	handler.shutdownAll(ctx)
+
 	handler.C <- "0"
 }
 
@@ -117,27 +197,55 @@ func (ln tcpKeepAliveListener) Accept() (net.Conn, error) {
 	return tc, nil
 }
 
-// serveHTTP sets up an HTTP server to listen for incoming requests.
-func serveHTTP(handler HandlerHTTP) {
-	server := http.Server{
+// listen creates the HTTP server and the listener it will serve requests on.
+func (handler *HandlerHTTP) listen() (*http.Server, net.Listener, error) {
+	server := &http.Server{
 		ReadHeaderTimeout: ten * time.Second,
 		ReadTimeout:       thirty * time.Second,
 		IdleTimeout:       five * time.Minute,
-		Handler:           &handler,
+		Handler:           handler.Handler(),
+		TLSConfig:         handler.TLSConfig,
+	}
+
+	if err := handler.configureHTTP2(server); err != nil {
+		return nil, nil, err
 	}
 
 	listenAddr := fmt.Sprintf("%s:%d", handler.Address, handler.ListenPort)
 
 	listener, err := net.Listen("tcp4", listenAddr)
 	if err != nil {
-		log.Fatalf("unable create listener, %s", err)
+		return nil, nil, err
 	}
 
 	ln := tcpKeepAliveListener{listener.(*net.TCPListener)}
 
-	log.Infof("Listening for HTTP requests on %d", handler.ListenPort)
+	handler.mutex.Lock()
+	handler.Server = server
+	handler.listener = ln
+	handler.mutex.Unlock()
+
+	return server, ln, nil
+}
+
+// serveHTTP serves incoming requests on the listener until the server is shutdown.
+func serveHTTP(handler *HandlerHTTP, server *http.Server, ln net.Listener) {
+	var err error
+
+	if handler.tlsEnabled() {
+		log.WithField("listener", handler.Name).Infof("Listening for HTTPS requests on %s", ln.Addr())
+
+		err = server.ServeTLS(ln, handler.CertFile, handler.KeyFile)
+	} else {
+		log.WithField("listener", handler.Name).Infof("Listening for HTTP requests on %s", ln.Addr())
+
+		err = server.Serve(ln)
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return
+	}
 
-	err = server.Serve(ln)
 	if err != nil {
 		log.Fatalf("unable listen, %s", err)
 	}
@@ -0,0 +1,318 @@
+package httpserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// IdempotencyKeyHeader is the default request header holding the idempotency key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from the idempotency store.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// DefaultIdempotencyTTL is how long responses are kept by the in-memory store if no TTL is specified.
+	DefaultIdempotencyTTL = 24 * time.Hour
+)
+
+var (
+	ErrorIdempotencyInProgress = errors.New("request with the same idempotency key is in progress")
+	ErrorIdempotencyMismatch   = errors.New("idempotency key reused with a different request")
+)
+
+type (
+	// StoredResponse is a response held in an IdempotencyStore.
+	StoredResponse struct {
+		Fingerprint string      // Hash of the request method, path and body the response was generated for.
+		Status      int         // Response status code.
+		Header      http.Header // Response headers.
+		Body        []byte      // Response body.
+	}
+
+	// IdempotencyStore is the interface used to store responses to requests with an idempotency key.
+	IdempotencyStore interface {
+		// Begin reserves a key, returning the stored response if the key has completed
+		// or ErrorIdempotencyInProgress if another request holds the reservation.
+		Begin(key string) (*StoredResponse, error)
+		// Complete stores the response for a reserved key and releases the reservation.
+		Complete(key string, resp *StoredResponse)
+		// Abort releases the reservation without storing a response so the request can be retried.
+		Abort(key string)
+	}
+
+	// IdempotencyOptions enables idempotency handling for MuxHTTP handlers.
+	IdempotencyOptions struct {
+		Store   IdempotencyStore // Store holding responses, defaults to an in-memory store with DefaultIdempotencyTTL.
+		Header  string           // Request header holding the key, defaults to IdempotencyKeyHeader.
+		Methods []string         // Methods idempotency applies to, defaults to POST.
+	}
+
+	// memoryEntry is an entry in the in-memory store.
+	memoryEntry struct {
+		resp    *StoredResponse
+		expires time.Time
+	}
+
+	// memoryIdempotencyStore is an in-memory IdempotencyStore that expires responses after a TTL.
+	// Expired responses are removed when their key is next used and by a sweep of all entries at most once per TTL.
+	memoryIdempotencyStore struct {
+		mutex     sync.Mutex
+		ttl       time.Duration
+		entries   map[string]*memoryEntry
+		nextSweep time.Time
+	}
+
+	// captureWriter records the response written by a handler while passing it on to the client.
+	captureWriter struct {
+		http.ResponseWriter
+		status int
+		body   bytes.Buffer
+	}
+)
+
+// NewMemoryIdempotencyStore returns an in-memory IdempotencyStore that keeps responses for ttl.
+func NewMemoryIdempotencyStore(ttl time.Duration) IdempotencyStore {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+
+	return &memoryIdempotencyStore{ttl: ttl, entries: map[string]*memoryEntry{}, nextSweep: time.Now().Add(ttl)}
+}
+
+// Begin reserves a key or returns its stored response.
+func (m *memoryIdempotencyStore) Begin(key string) (*StoredResponse, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	if entry, ok := m.entries[key]; ok && !entry.expired(now) {
+		if entry.resp == nil {
+			return nil, ErrorIdempotencyInProgress
+		}
+
+		return entry.resp, nil
+	}
+
+	m.entries[key] = &memoryEntry{}
+
+	return nil, nil
+}
+
+// sweep removes expired responses if a TTL has passed since the last sweep.
+func (m *memoryIdempotencyStore) sweep(now time.Time) {
+	if now.Before(m.nextSweep) {
+		return
+	}
+
+	for key, entry := range m.entries {
+		if entry.expired(now) {
+			delete(m.entries, key)
+		}
+	}
+
+	m.nextSweep = now.Add(m.ttl)
+}
+
+// expired returns true if the entry holds a response that has expired.
+func (e *memoryEntry) expired(now time.Time) bool {
+	return e.resp != nil && now.After(e.expires)
+}
+
+// Complete stores the response for a key.
+func (m *memoryIdempotencyStore) Complete(key string, resp *StoredResponse) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.entries[key] = &memoryEntry{resp: resp, expires: time.Now().Add(m.ttl)}
+}
+
+// Abort releases the reservation of a key.
+func (m *memoryIdempotencyStore) Abort(key string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if entry, ok := m.entries[key]; ok && entry.resp == nil {
+		delete(m.entries, key)
+	}
+}
+
+// WriteHeader records the status code.
+func (c *captureWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body.
+func (c *captureWriter) Write(data []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+
+	c.body.Write(data)
+
+	return c.ResponseWriter.Write(data)
+}
+
+// idempotencyKey returns the key for the request, or an empty string if idempotency handling does not apply.
+func (handler *HandlerHTTP) idempotencyKey(r *http.Request) string {
+	opts := handler.Idempotency
+	if opts == nil {
+		return ""
+	}
+
+	methods := opts.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodPost}
+	}
+
+	for _, method := range methods {
+		if method == r.Method {
+			header := opts.Header
+			if len(header) == 0 {
+				header = IdempotencyKeyHeader
+			}
+
+			return r.Header.Get(header)
+		}
+	}
+
+	return ""
+}
+
+// idempotencyStore returns the configured store, creating the default in-memory store if none is set.
+func (handler *HandlerHTTP) idempotencyStore() IdempotencyStore {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
+	if handler.Idempotency.Store == nil {
+		handler.Idempotency.Store = NewMemoryIdempotencyStore(DefaultIdempotencyTTL)
+	}
+
+	return handler.Idempotency.Store
+}
+
+// serveIdempotent calls the handler for the first request with a key and replays its response for duplicates.
+func (handler *HandlerHTTP) serveIdempotent(w http.ResponseWriter, r *http.Request, key string,
+	h func(http.ResponseWriter, *http.Request) (int, string)) {
+	fingerprint, err := requestFingerprint(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	store := handler.idempotencyStore()
+	storeKey := fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, key)
+
+	stored, err := store.Begin(storeKey)
+	if err != nil {
+		if errors.Is(err, ErrorIdempotencyInProgress) {
+			http.Error(w, err.Error(), http.StatusConflict)
+
+			return
+		}
+
+		http.Error(w, fmt.Sprintf("idempotency store failed, %s", err), http.StatusInternalServerError)
+
+		return
+	}
+
+	if stored != nil {
+		replayResponse(w, stored, fingerprint)
+
+		return
+	}
+
+	completed := false
+
+	defer func() {
+		if !completed {
+			store.Abort(storeKey)
+		}
+	}()
+
+	cw := &captureWriter{ResponseWriter: w}
+
+	code, msg := h(cw, r)
+	if !expectedHTTPstatus(code, r.Method) {
+		http.Error(cw, msg, code)
+	}
+
+	if cw.status == 0 {
+		// The handler wrote nothing so the server sends an empty 200 response.
+		cw.status = http.StatusOK
+	}
+
+	if cw.status >= http.StatusInternalServerError {
+		return
+	}
+
+	store.Complete(storeKey, &StoredResponse{
+		Fingerprint: fingerprint,
+		Status:      cw.status,
+		Header:      w.Header().Clone(),
+		Body:        cw.body.Bytes(),
+	})
+
+	completed = true
+}
+
+// replayResponse writes a stored response, or rejects the request if it differs from the one that generated the response.
+func replayResponse(w http.ResponseWriter, stored *StoredResponse, fingerprint string) {
+	if stored.Fingerprint != fingerprint {
+		http.Error(w, ErrorIdempotencyMismatch.Error(), http.StatusUnprocessableEntity)
+
+		return
+	}
+
+	for name, values := range stored.Header {
+		w.Header()[name] = values
+	}
+
+	status := stored.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(status)
+
+	if _, err := w.Write(stored.Body); err != nil {
+		log.Errorf("failed to write replayed response, %s", err)
+	}
+}
+
+// requestFingerprint returns a hash of the request method, path and body, leaving the body available to the handler.
+func requestFingerprint(r *http.Request) (string, error) {
+	var data []byte
+
+	if r.Body != nil {
+		var err error
+
+		data, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			return "", readingBodyError(err.Error())
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.Path)
+	hash.Write(data)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
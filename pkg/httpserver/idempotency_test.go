@@ -0,0 +1,139 @@
+package httpserver_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpserver"
+)
+
+func idempotentRequest(handler *httpserver.HandlerHTTP, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if len(key) > 0 {
+		req.Header.Set(httpserver.IdempotencyKeyHeader, key)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestIdempotency(t *testing.T) {
+	var calls, flakyCalls int32
+
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := &httpserver.HandlerHTTP{
+		Idempotency: &httpserver.IdempotencyOptions{},
+		Mux: httpserver.MuxHTTP{
+			"/create": func(w http.ResponseWriter, r *http.Request) (int, string) {
+				return httpserver.JSONresponse(w, fmt.Sprintf(`{"call":%d}`, atomic.AddInt32(&calls, 1)))
+			},
+			"/flaky": func(w http.ResponseWriter, r *http.Request) (int, string) {
+				if atomic.AddInt32(&flakyCalls, 1)%2 == 1 {
+					return http.StatusServiceUnavailable, "try again"
+				}
+
+				return httpserver.JSONresponse(w, `{"ok":true}`)
+			},
+			"/empty": func(w http.ResponseWriter, r *http.Request) (int, string) {
+				atomic.AddInt32(&calls, 1)
+
+				return http.StatusOK, ""
+			},
+			"/slow": func(w http.ResponseWriter, r *http.Request) (int, string) {
+				close(started)
+				<-release
+
+				return httpserver.JSONresponse(w, `{}`)
+			},
+		},
+	}
+
+	tests := []struct {
+		testNum  int
+		path     string
+		key      string
+		body     string
+		expected int
+		replayed string
+		contains string
+	}{
+		{1, "/create", "key-1", `{"a":1}`, http.StatusOK, "", `{"call":1}`},
+		{2, "/create", "key-1", `{"a":1}`, http.StatusOK, "true", `{"call":1}`},
+		{3, "/create", "key-1", `{"a":2}`, http.StatusUnprocessableEntity, "", "different request"},
+		{4, "/create", "", `{"a":1}`, http.StatusOK, "", `{"call":2}`},
+		{5, "/create", "key-2", `{"a":1}`, http.StatusOK, "", `{"call":3}`},
+		{6, "/flaky", "key-1", `{}`, http.StatusServiceUnavailable, "", "try again"},
+		{7, "/flaky", "key-1", `{}`, http.StatusOK, "", `{"ok":true}`},
+		{8, "/flaky", "key-1", `{}`, http.StatusOK, "true", `{"ok":true}`},
+		{9, "/empty", "key-1", `{}`, http.StatusOK, "", ""},
+		{10, "/empty", "key-1", `{}`, http.StatusOK, "true", ""},
+	}
+
+	for _, test := range tests {
+		w := idempotentRequest(handler, test.path, test.key, test.body)
+		if w.Code != test.expected || w.Header().Get(httpserver.IdempotentReplayedHeader) != test.replayed || !strings.Contains(w.Body.String(), test.contains) {
+			t.Errorf("\nTest: %d\nExpected: %d, replayed: %s, containing %s\nGot.....: %d, replayed: %s, %s", test.testNum,
+				test.expected, test.replayed, test.contains, w.Code, w.Header().Get(httpserver.IdempotentReplayedHeader), w.Body.String())
+		}
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+
+	go func() { done <- idempotentRequest(handler, "/slow", "key-1", "") }()
+
+	<-started
+
+	if w := idempotentRequest(handler, "/slow", "key-1", ""); w.Code != http.StatusConflict {
+		t.Errorf("\nExpected: %d\nGot.....: %d, %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	close(release)
+
+	if w := <-done; w.Code != http.StatusOK {
+		t.Errorf("first concurrent request failed: %d, %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	store := httpserver.NewMemoryIdempotencyStore(10 * time.Millisecond)
+
+	if resp, err := store.Begin("key"); resp != nil || err != nil {
+		t.Fatalf("expected key to be reserved, got %v, %s", resp, err)
+	}
+
+	if _, err := store.Begin("key"); !errors.Is(err, httpserver.ErrorIdempotencyInProgress) {
+		t.Fatalf("expected in progress error, got %s", err)
+	}
+
+	store.Abort("key")
+
+	if resp, err := store.Begin("key"); resp != nil || err != nil {
+		t.Fatalf("expected key to be reserved after abort, got %v, %s", resp, err)
+	}
+
+	store.Complete("key", &httpserver.StoredResponse{Status: http.StatusOK})
+
+	if resp, err := store.Begin("key"); err != nil || resp == nil || resp.Status != http.StatusOK {
+		t.Fatalf("expected stored response, got %v, %s", resp, err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if resp, err := store.Begin("key"); resp != nil || err != nil {
+		t.Fatalf("expected stored response to expire, got %v, %s", resp, err)
+	}
+
+	store.Complete("key", &httpserver.StoredResponse{Status: http.StatusCreated})
+
+	if resp, err := store.Begin("key"); err != nil || resp == nil || resp.Status != http.StatusCreated {
+		t.Fatalf("expected response stored after expiry, got %v, %s", resp, err)
+	}
+}
@@ -0,0 +1,117 @@
+package httpserver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Middleware is a function that wraps an http.Handler, adding behaviour to the requests it serves.
+type Middleware func(http.Handler) http.Handler
+
+// Handler returns the handler wrapped in its middleware.
+func (handler *HandlerHTTP) Handler() http.Handler {
+	var h http.Handler = handler
+
+	for index := len(handler.Middleware) - 1; index >= 0; index-- {
+		h = handler.Middleware[index](h)
+	}
+
+	return h
+}
+
+// Listener returns the listener with the given name, this one or one of its Listeners, or nil if there is no such listener.
+func (handler *HandlerHTTP) Listener(name string) *HandlerHTTP {
+	if handler.Name == name {
+		return handler
+	}
+
+	for _, listener := range handler.Listeners {
+		if listener.Name == name {
+			return listener
+		}
+	}
+
+	return nil
+}
+
+// all returns this listener followed by its additional listeners.
+func (handler *HandlerHTTP) all() []*HandlerHTTP {
+	return append([]*HandlerHTTP{handler}, handler.Listeners...)
+}
+
+// startAll creates all the listeners and then serves requests on them, if any listener cannot be created none are started.
+func (handler *HandlerHTTP) startAll() error {
+	handlers := handler.all()
+	servers := make([]*http.Server, 0, len(handlers))
+	listeners := make([]net.Listener, 0, len(handlers))
+
+	for _, h := range handlers {
+		if h.C == nil {
+			h.C = handler.C
+		}
+
+		server, ln, err := h.listen()
+		if err != nil {
+			for _, l := range listeners {
+				if e := l.Close(); e != nil {
+					log.Errorf("failed to close listener, %s", e)
+				}
+			}
+
+			for _, started := range handlers {
+				started.reset()
+			}
+
+			return err
+		}
+
+		servers = append(servers, server)
+		listeners = append(listeners, ln)
+	}
+
+	for index, h := range handlers {
+		go serveHTTP(h, servers[index], listeners[index])
+	}
+
+	return nil
+}
+
+// reset clears the server and listener of a listener that failed to start.
+func (handler *HandlerHTTP) reset() {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
+	handler.Server = nil
+	handler.listener = nil
+}
+
+// shutdownAll gracefully stops all the listeners in parallel.
+func (handler *HandlerHTTP) shutdownAll(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, h := range handler.all() {
+		h.mutex.Lock()
+		server := h.Server
+		h.mutex.Unlock()
+
+		if server == nil {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(name string, server *http.Server) {
+			defer wg.Done()
+
+			if err := server.Shutdown(ctx); err != nil {
+				log.WithField("listener", name).Errorf("failed to shutdown server, %s", err)
+			}
+		}(h.Name, server)
+	}
+
+	wg.Wait()
+}
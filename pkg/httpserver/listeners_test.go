@@ -0,0 +1,144 @@
+package httpserver_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpserver"
+)
+
+func okMux(path string) httpserver.MuxHTTP {
+	return httpserver.MuxHTTP{
+		path: func(w http.ResponseWriter, r *http.Request) (int, string) {
+			return httpserver.JSONresponse(w, `{}`)
+		},
+	}
+}
+
+func headerMiddleware(name, value string) httpserver.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(name, value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestListeners(t *testing.T) {
+	handler := &httpserver.HandlerHTTP{
+		Name:    "public",
+		Address: "127.0.0.1",
+		C:       make(chan string, 1),
+		Mux:     okMux("/api"),
+		Listeners: []*httpserver.HandlerHTTP{
+			{
+				Name:       "admin",
+				Address:    "127.0.0.1",
+				Debug:      true,
+				Auth:       &httpserver.AuthOptions{Username: "admin", Password: "pass"},
+				Middleware: []httpserver.Middleware{headerMiddleware("X-Listener", "admin"), headerMiddleware("X-Listener", "inner")},
+			},
+			{Name: "metrics", Address: "127.0.0.1", Mux: okMux("/metrics")},
+		},
+	}
+
+	handler.Start(nil)
+
+	tests := []struct {
+		testNum  int
+		listener string
+		path     string
+		user     string
+		expected int
+		header   string
+	}{
+		{1, "public", "/api", "", http.StatusOK, ""},
+		{2, "public", "/metrics", "", http.StatusBadRequest, ""},
+		{3, "metrics", "/metrics", "", http.StatusOK, ""},
+		{4, "metrics", "/api", "", http.StatusBadRequest, ""},
+		{5, "admin", "/debug/loglevel", "", http.StatusUnauthorized, "inner"},
+		{6, "admin", "/debug/loglevel", "admin", http.StatusOK, "inner"},
+		{7, "public", "/debug/loglevel", "", http.StatusBadRequest, ""},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s", handler.Listener(test.listener).Addr(), test.path), nil) // nolint:noctx // ok
+		if err != nil {
+			t.Fatalf("failed to create request, %s", err)
+		}
+
+		if len(test.user) > 0 {
+			req.SetBasicAuth(test.user, "pass")
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed, %s", err)
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected || resp.Header.Get("X-Listener") != test.header {
+			t.Errorf("\nTest: %d\nExpected: %d, %s\nGot.....: %d, %s", test.testNum, test.expected, test.header, resp.StatusCode, resp.Header.Get("X-Listener"))
+		}
+	}
+
+	if handler.Listener("unknown") != nil {
+		t.Errorf("expected nil for unknown listener")
+	}
+
+	handler.Shutdown()
+
+	for _, name := range []string{"public", "admin", "metrics"} {
+		if conn, err := net.Dial("tcp", handler.Listener(name).Addr()); err == nil {
+			conn.Close()
+			t.Errorf("listener %s still accepting connections after shutdown", name)
+		}
+	}
+}
+
+func TestListenersRestart(t *testing.T) {
+	busy, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create listener, %s", err)
+	}
+
+	handler := &httpserver.HandlerHTTP{
+		Name:      "public",
+		Address:   "127.0.0.1",
+		C:         make(chan string, 1),
+		Mux:       okMux("/api"),
+		Listeners: []*httpserver.HandlerHTTP{{Name: "busy", Address: "127.0.0.1", ListenPort: busy.Addr().(*net.TCPAddr).Port}},
+	}
+
+	if err := handler.StartAll(); err == nil {
+		t.Fatalf("expected start to fail with a port in use")
+	}
+
+	if handler.Addr() != "" || handler.Server != nil || handler.Listener("busy").Addr() != "" {
+		t.Errorf("expected failed listeners to be reset, got %q, %v", handler.Addr(), handler.Server)
+	}
+
+	busy.Close()
+
+	if err := handler.StartAll(); err != nil {
+		t.Fatalf("failed to start after the port was freed, %s", err)
+	}
+
+	for _, name := range []string{"public", "busy"} {
+		resp, err := http.Get(fmt.Sprintf("http://%s/api", handler.Listener(name).Addr())) // nolint:noctx // ok
+		if err != nil {
+			t.Fatalf("request to %s failed, %s", name, err)
+		}
+
+		resp.Body.Close()
+
+		if name == "public" && resp.StatusCode != http.StatusOK {
+			t.Errorf("expected %s to serve requests, got %d", name, resp.StatusCode)
+		}
+	}
+
+	handler.Shutdown()
+}
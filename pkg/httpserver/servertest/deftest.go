@@ -0,0 +1,148 @@
+package servertest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpserver"
+	"github.com/paulcarlton-ww/goutils/pkg/testutils"
+)
+
+type (
+	// Case is the testutils.DefTest Config describing the request to send.
+	Case struct {
+		Method  string            // Request method, defaults to GET.
+		Path    string            // Request path, may include a query string.
+		Headers map[string]string // Request headers.
+		Body    interface{}       // Request body, a string is sent as is, other values are sent as JSON.
+	}
+
+	// Expect is the testutils.DefTest Expected value describing the expected response.
+	// Only the headers, JSON paths and body text listed are checked.
+	Expect struct {
+		Status       int                    // Expected status code.
+		Headers      map[string]string      // Expected header values.
+		JSON         map[string]interface{} // Expected values of JSON paths in the body.
+		BodyContains []string               // Text the body is expected to contain.
+	}
+)
+
+// Send sends the request described by a Case.
+func (h *Harness) Send(c *Case) *Response {
+	method := c.Method
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+
+	req := h.Request(method, c.Path)
+
+	for name, value := range c.Headers {
+		req.Header(name, value)
+	}
+
+	switch body := c.Body.(type) {
+	case nil:
+	case string:
+		req.Body(body)
+	default:
+		req.JSON(body)
+	}
+
+	return req.Do()
+}
+
+// Actual returns the response as an Expect, containing the fields listed in the expected value.
+// Missing headers and JSON paths are omitted so they are reported as differences.
+func (r *Response) Actual(expected *Expect) *Expect {
+	actual := &Expect{Status: r.Code()}
+
+	if expected.Headers != nil {
+		actual.Headers = map[string]string{}
+
+		for name := range expected.Headers {
+			if values, ok := r.Recorder.Header()[http.CanonicalHeaderKey(name)]; ok {
+				actual.Headers[name] = strings.Join(values, ",")
+			}
+		}
+	}
+
+	if expected.JSON != nil {
+		actual.JSON = map[string]interface{}{}
+
+		for path := range expected.JSON {
+			if value, ok := r.JSON(path); ok {
+				actual.JSON[path] = value
+			}
+		}
+	}
+
+	for _, text := range expected.BodyContains {
+		if strings.Contains(r.Body(), text) {
+			actual.BodyContains = append(actual.BodyContains, text)
+		}
+	}
+
+	return actual
+}
+
+// RunTests runs table driven tests against the handler.
+// Each test's Config must be a *Case and its Expected a single *Expect, the actual *Expect is checked using the
+// test's CheckFunc, or the default check function if not set. The tests are not modified so they can be run again.
+func RunTests(t *testing.T, handler *httpserver.HandlerHTTP, tests []*testutils.DefTest) {
+	h := New(t, handler)
+
+	for _, test := range tests {
+		if !runTest(t, h, test) {
+			return
+		}
+	}
+}
+
+func runTest(t *testing.T, h *Harness, def *testutils.DefTest) bool {
+	defer testutils.HandlePanic(t)
+
+	// Work on a copy so the caller's test and expectation are unchanged.
+	copied := *def
+	test := &copied
+
+	u := testutils.NewTestUtil(t, test)
+	u.CallPrepFunc()
+
+	c, ok := test.Config.(*Case)
+	if !ok {
+		t.Fatalf("Test: %d, %s, Config is not a *servertest.Case", test.Number, test.Description)
+	}
+
+	if len(test.Expected) != 1 {
+		t.Fatalf("Test: %d, %s, Expected must hold a single *servertest.Expect", test.Number, test.Description)
+	}
+
+	expected, ok := test.Expected[0].(*Expect)
+	if !ok {
+		t.Fatalf("Test: %d, %s, Expected[0] is not a *servertest.Expect", test.Number, test.Description)
+	}
+
+	normalized := *expected
+	normalized.JSON = normalizeJSONMap(expected.JSON)
+	test.Expected = []interface{}{&normalized}
+	test.Inputs = []interface{}{c}
+	test.Results = []interface{}{h.Send(c).Actual(&normalized)}
+
+	return u.CallCheckFunc()
+}
+
+// normalizeJSONMap converts the expected JSON values to the form they have when decoded so they can be compared.
+func normalizeJSONMap(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	result := make(map[string]interface{}, len(values))
+
+	for path, value := range values {
+		result[path] = normalizeJSON(value)
+	}
+
+	return result
+}
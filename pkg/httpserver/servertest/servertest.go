@@ -0,0 +1,269 @@
+// Package servertest provides a harness for testing httpserver handlers in-process, without starting a server.
+package servertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpserver"
+)
+
+// Harness drives a HandlerHTTP's ServeHTTP method, including its middleware, in-process.
+type Harness struct {
+	t       *testing.T
+	handler http.Handler
+}
+
+// Request is a fluent builder for a request sent to the harness.
+type Request struct {
+	harness *Harness
+	method  string
+	path    string
+	query   url.Values
+	header  http.Header
+	body    io.Reader
+}
+
+// Response holds the response to a request and provides fluent assertions on it.
+type Response struct {
+	t *testing.T
+	// Recorder holds the recorded response.
+	Recorder *httptest.ResponseRecorder
+}
+
+// New returns a Harness that sends requests to the handler.
+func New(t *testing.T, handler *httpserver.HandlerHTTP) *Harness {
+	return &Harness{t: t, handler: handler.Handler()}
+}
+
+// Request returns a builder for a request with the given method and path.
+func (h *Harness) Request(method, path string) *Request {
+	return &Request{harness: h, method: method, path: path, query: url.Values{}, header: http.Header{}}
+}
+
+// Get returns a builder for a GET request.
+func (h *Harness) Get(path string) *Request {
+	return h.Request(http.MethodGet, path)
+}
+
+// Post returns a builder for a POST request.
+func (h *Harness) Post(path string) *Request {
+	return h.Request(http.MethodPost, path)
+}
+
+// Put returns a builder for a PUT request.
+func (h *Harness) Put(path string) *Request {
+	return h.Request(http.MethodPut, path)
+}
+
+// Patch returns a builder for a PATCH request.
+func (h *Harness) Patch(path string) *Request {
+	return h.Request(http.MethodPatch, path)
+}
+
+// Delete returns a builder for a DELETE request.
+func (h *Harness) Delete(path string) *Request {
+	return h.Request(http.MethodDelete, path)
+}
+
+// Header sets a request header.
+func (r *Request) Header(name, value string) *Request {
+	r.header.Set(name, value)
+
+	return r
+}
+
+// Query adds a query parameter.
+func (r *Request) Query(name, value string) *Request {
+	r.query.Add(name, value)
+
+	return r
+}
+
+// BasicAuth sets basic authentication credentials.
+func (r *Request) BasicAuth(username, password string) *Request {
+	req := http.Request{Header: http.Header{}}
+	req.SetBasicAuth(username, password)
+
+	return r.Header("Authorization", req.Header.Get("Authorization"))
+}
+
+// BearerToken sets a bearer token.
+func (r *Request) BearerToken(token string) *Request {
+	return r.Header("Authorization", "Bearer "+token)
+}
+
+// Body sets the request body.
+func (r *Request) Body(body string) *Request {
+	r.body = strings.NewReader(body)
+
+	return r
+}
+
+// JSON sets the request body to the JSON encoding of data and the content type to JSON.
+func (r *Request) JSON(data interface{}) *Request {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		r.harness.t.Fatalf("failed to convert request body to json, %s", err)
+	}
+
+	r.Header(httpserver.ContentType, httpserver.AppJSON)
+
+	return r.Body(string(jsonData))
+}
+
+// Do sends the request to the handler and returns the response.
+func (r *Request) Do() *Response {
+	target := r.path
+	if len(r.query) > 0 {
+		target = fmt.Sprintf("%s?%s", target, r.query.Encode())
+	}
+
+	req := httptest.NewRequest(r.method, target, r.body)
+
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+
+	w := httptest.NewRecorder()
+	r.harness.handler.ServeHTTP(w, req)
+
+	return &Response{t: r.harness.t, Recorder: w}
+}
+
+// Code returns the response status code.
+func (r *Response) Code() int {
+	return r.Recorder.Code
+}
+
+// Body returns the response body.
+func (r *Response) Body() string {
+	return r.Recorder.Body.String()
+}
+
+// JSON returns the value at a path in the JSON response body, see JSONPathValue for the path syntax.
+func (r *Response) JSON(path string) (interface{}, bool) {
+	var data interface{}
+	if err := json.Unmarshal(r.Recorder.Body.Bytes(), &data); err != nil {
+		return nil, false
+	}
+
+	return JSONPathValue(data, path)
+}
+
+// Status asserts the response status code.
+func (r *Response) Status(expected int) *Response {
+	r.t.Helper()
+
+	if r.Code() != expected {
+		r.t.Errorf("\nStatus..: unexpected\nGot.....: %d\nExpected: %d\nBody....: %s", r.Code(), expected, r.Body())
+	}
+
+	return r
+}
+
+// Header asserts the value of a response header.
+func (r *Response) Header(name, expected string) *Response {
+	r.t.Helper()
+
+	if actual := r.Recorder.Header().Get(name); actual != expected {
+		r.t.Errorf("\nHeader..: %s\nGot.....: %s\nExpected: %s", name, actual, expected)
+	}
+
+	return r
+}
+
+// BodyContains asserts the response body contains the text.
+func (r *Response) BodyContains(text string) *Response {
+	r.t.Helper()
+
+	if !strings.Contains(r.Body(), text) {
+		r.t.Errorf("\nBody....: %s\nExpected to contain: %s", r.Body(), text)
+	}
+
+	return r
+}
+
+// JSONPath asserts the value at a path in the JSON response body.
+// The expected value is compared after conversion to JSON so an int matches the float64 decoded from the body.
+func (r *Response) JSONPath(path string, expected interface{}) *Response {
+	r.t.Helper()
+
+	actual, ok := r.JSON(path)
+	if !ok {
+		r.t.Errorf("\nJSON path: %s, not found in body: %s", path, r.Body())
+
+		return r
+	}
+
+	if !jsonEqual(actual, expected) {
+		r.t.Errorf("\nJSON path: %s\nGot.....: %v\nExpected: %v", path, actual, expected)
+	}
+
+	return r
+}
+
+// JSONPathValue returns the value at a dot separated path in decoded JSON data, numeric elements index arrays, e.g. "items.0.name".
+// An empty path returns the data.
+func JSONPathValue(data interface{}, path string) (interface{}, bool) {
+	if len(path) == 0 {
+		return data, true
+	}
+
+	for _, element := range strings.Split(path, ".") {
+		switch value := data.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if data, ok = value[element]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(element)
+			if err != nil || index < 0 || index >= len(value) {
+				return nil, false
+			}
+
+			data = value[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return data, true
+}
+
+// normalizeJSON converts a value to the form it would have if decoded from JSON.
+func normalizeJSON(value interface{}) interface{} {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(jsonData, &result); err != nil {
+		return value
+	}
+
+	return result
+}
+
+func jsonEqual(actual, expected interface{}) bool {
+	a, err := json.Marshal(normalizeJSON(actual))
+	if err != nil {
+		return false
+	}
+
+	e, err := json.Marshal(normalizeJSON(expected))
+	if err != nil {
+		return false
+	}
+
+	return string(a) == string(e)
+}
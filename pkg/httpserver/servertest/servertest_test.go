@@ -0,0 +1,128 @@
+package servertest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/paulcarlton-ww/goutils/pkg/httpserver"
+	"github.com/paulcarlton-ww/goutils/pkg/httpserver/servertest"
+	"github.com/paulcarlton-ww/goutils/pkg/testutils"
+)
+
+func itemsHandler() *httpserver.HandlerHTTP {
+	return &httpserver.HandlerHTTP{
+		Auth:    &httpserver.AuthOptions{BearerTokens: []string{"token"}},
+		AuthAll: true,
+		Mux: httpserver.MuxHTTP{
+			"/items": func(w http.ResponseWriter, r *http.Request) (int, string) {
+				if r.Method == http.MethodPost {
+					body, err := httpserver.GetReqBody(r)
+					if err != nil {
+						return http.StatusBadRequest, err.Error()
+					}
+
+					item := map[string]interface{}{}
+					if err := json.Unmarshal([]byte(body), &item); err != nil {
+						return http.StatusBadRequest, err.Error()
+					}
+
+					return httpserver.JSONresponse(w, fmt.Sprintf(`{"created":%q}`, item["name"]))
+				}
+
+				return httpserver.JSONresponse(w, fmt.Sprintf(`{"filter":%q,"items":[{"name":"a","count":1},{"name":"b","count":2}]}`,
+					r.URL.Query().Get("filter")))
+			},
+		},
+	}
+}
+
+func TestHarness(t *testing.T) {
+	h := servertest.New(t, itemsHandler())
+
+	h.Get("/items").Do().Status(http.StatusUnauthorized)
+
+	h.Get("/items").BearerToken("token").Query("filter", "all").Do().
+		Status(http.StatusOK).
+		Header(httpserver.ContentType, httpserver.AppJSON).
+		JSONPath("filter", "all").
+		JSONPath("items.1.name", "b").
+		JSONPath("items.1.count", 2).
+		BodyContains(`"name":"a"`)
+
+	h.Post("/items").BearerToken("token").JSON(map[string]string{"name": "c"}).Do().
+		Status(http.StatusOK).
+		JSONPath("created", "c")
+
+	h.Get("/unknown").BearerToken("token").Do().Status(http.StatusBadRequest).BodyContains("unrecognised request")
+}
+
+func TestJSONPathValue(t *testing.T) {
+	data := map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": "c"}}}
+
+	tests := []struct {
+		testNum  int
+		path     string
+		expected interface{}
+		found    bool
+	}{
+		{1, "a.0.b", "c", true},
+		{2, "a.1.b", nil, false},
+		{3, "a.x", nil, false},
+		{4, "a.0.b.c", nil, false},
+		{5, "", data, true},
+	}
+
+	for _, test := range tests {
+		result, found := servertest.JSONPathValue(data, test.path)
+		if found != test.found || fmt.Sprint(result) != fmt.Sprint(test.expected) {
+			t.Errorf("\nTest: %d\nExpected: %v, %t\nGot.....: %v, %t", test.testNum, test.expected, test.found, result, found)
+		}
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	auth := map[string]string{"Authorization": "Bearer token"}
+	tests := []*testutils.DefTest{
+		{
+			Number:      1,
+			Description: "unauthorized",
+			Config:      &servertest.Case{Path: "/items"},
+			Expected:    []interface{}{&servertest.Expect{Status: http.StatusUnauthorized, Headers: map[string]string{"WWW-Authenticate": "Bearer"}}},
+		},
+		{
+			Number:      2,
+			Description: "list items",
+			Config:      &servertest.Case{Path: "/items?filter=some", Headers: auth},
+			Expected: []interface{}{&servertest.Expect{
+				Status:       http.StatusOK,
+				Headers:      map[string]string{httpserver.ContentType: httpserver.AppJSON},
+				JSON:         map[string]interface{}{"filter": "some", "items.0.count": 1},
+				BodyContains: []string{`"name":"b"`},
+			}},
+		},
+		{
+			Number:      3,
+			Description: "create item",
+			Config:      &servertest.Case{Method: http.MethodPost, Path: "/items", Headers: auth, Body: map[string]string{"name": "d"}},
+			Expected:    []interface{}{&servertest.Expect{Status: http.StatusOK, JSON: map[string]interface{}{"created": "d"}}},
+		},
+		{
+			Number:      4,
+			Description: "invalid body",
+			Config:      &servertest.Case{Method: http.MethodPost, Path: "/items", Headers: auth, Body: "not json"},
+			Expected:    []interface{}{&servertest.Expect{Status: http.StatusBadRequest, BodyContains: []string{"invalid character"}}},
+		},
+	}
+
+	// The tests are not modified so they can be run again with the same results.
+	for run := 1; run <= 2; run++ {
+		servertest.RunTests(t, itemsHandler(), tests)
+
+		expected, ok := tests[1].Expected[0].(*servertest.Expect)
+		if !ok || expected.JSON["items.0.count"] != 1 || tests[1].Results != nil {
+			t.Errorf("run %d modified the test, %+v, %v", run, tests[1].Expected[0], tests[1].Results)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"sync/atomic"
 
 	"github.com/go-logr/logr"
 	uzap "go.uber.org/zap"
@@ -32,21 +33,34 @@ const (
 	traceLevelEnvVar       = "TRACE_LEVEL"
 )
 
-var TraceLevel = four // nolint:gochecknoglobals //ok
+// traceLevel is the trace level, set at startup from the TRACE_LEVEL environment variable.
+var traceLevel int32 = four // nolint:gochecknoglobals //ok
 
 var errNotAvailable = errors.New("caller not availalble")
 
 func init() { // nolint:gochecknoinits //ok
 	if tlevel, ok := os.LookupEnv(traceLevelEnvVar); ok {
-		var err error
-		if TraceLevel, err = strconv.Atoi(tlevel); err != nil {
+		level, err := strconv.Atoi(tlevel)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "invalid 'TRACE_LEVEL' value: %s", tlevel)
 
-			TraceLevel = four
+			level = four
 		}
+
+		SetTraceLevel(level)
 	}
 }
 
+// GetTraceLevel returns the verbosity level used for tracing.
+func GetTraceLevel() int {
+	return int(atomic.LoadInt32(&traceLevel))
+}
+
+// SetTraceLevel sets the verbosity level used for tracing, it may be called while other goroutines are logging.
+func SetTraceLevel(level int) {
+	atomic.StoreInt32(&traceLevel, int32(level))
+}
+
 // NewLogger returns a logger configured the timestamps format is ISO8601.
 func NewLogger(name string, logOpts *zap.Options) logr.Logger {
 	encCfg := uzap.NewProductionEncoderConfig()
@@ -185,13 +199,13 @@ func CallerStr(skip uint) string {
 // TraceCall traces calls and exit for functions.
 func TraceCall(log logr.Logger) {
 	callerInfo := GetCaller(MyCaller, true)
-	log.V(TraceLevel).Info("Entering function", "function", callerInfo.FunctionName, "source", callerInfo.SourceFile, "line", callerInfo.SourceLine)
+	log.V(GetTraceLevel()).Info("Entering function", "function", callerInfo.FunctionName, "source", callerInfo.SourceFile, "line", callerInfo.SourceLine)
 }
 
 // TraceExit traces calls and exit for functions.
 func TraceExit(log logr.Logger) {
 	callerInfo := GetCaller(MyCaller, true)
-	log.V(TraceLevel).Info("Exiting function", "function", callerInfo.FunctionName, "source", callerInfo.SourceFile, "line", callerInfo.SourceLine)
+	log.V(GetTraceLevel()).Info("Exiting function", "function", callerInfo.FunctionName, "source", callerInfo.SourceFile, "line", callerInfo.SourceLine)
 }
 
 // GetFunctionAndSource gets function name and source line for logging.